@@ -0,0 +1,306 @@
+package letswatch
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// Kinds of bracket
+const (
+	// BracketSingle knocks out the loser of each match until one is left
+	BracketSingle = "single"
+	// BracketSwiss pairs films with similar records for a fixed number of
+	// rounds, so nothing is out after a single loss. Any tie for first is
+	// settled with a single elimination playoff
+	BracketSwiss = "swiss"
+)
+
+// BracketMatch is a single "A or B?" choice
+type BracketMatch struct {
+	Round int
+	A     *Movie
+	// B is nil for a bye, which A wins straight away
+	B *Movie
+	// Winner is nil until the match is decided
+	Winner *Movie
+}
+
+// Bracket runs head to head matches between films until there is a winner
+type Bracket struct {
+	Mode     string
+	entrants []*Movie
+	// choices are whether B won each decided match, so the bracket can be
+	// replayed for Undo
+	choices []bool
+
+	round   int
+	matches []*BracketMatch
+	// alive is who is left in single elimination, including a Swiss playoff
+	alive   []*Movie
+	playoff bool
+	// Swiss standings
+	swissRounds int
+	wins        map[*Movie]int
+	played      map[[2]*Movie]bool
+	hadBye      map[*Movie]bool
+}
+
+// NewBracket starts a bracket between the movies, seeded in the order given
+func NewBracket(movies []*Movie, mode string) (*Bracket, error) {
+	if len(movies) < 2 {
+		return nil, errors.New("a bracket needs at least 2 films")
+	}
+	switch mode {
+	case BracketSingle, BracketSwiss:
+	default:
+		return nil, fmt.Errorf("unknown bracket mode: %v", mode)
+	}
+	b := &Bracket{
+		Mode:     mode,
+		entrants: append([]*Movie{}, movies...),
+	}
+	b.reset()
+	return b, nil
+}
+
+func (b *Bracket) reset() {
+	b.round = 0
+	b.matches = nil
+	b.playoff = false
+	b.alive = append([]*Movie{}, b.entrants...)
+	b.wins = map[*Movie]int{}
+	b.played = map[[2]*Movie]bool{}
+	b.hadBye = map[*Movie]bool{}
+	// Enough rounds that only one film could win them all
+	b.swissRounds = bits.Len(uint(len(b.entrants) - 1))
+	b.nextRound()
+}
+
+// Entrants are all the films in the bracket, in seed order
+func (b *Bracket) Entrants() []*Movie {
+	return b.entrants
+}
+
+// Round is the current round, counting from 1
+func (b *Bracket) Round() int {
+	return b.round
+}
+
+// Matches are all the matches so far, including byes and the current round
+func (b *Bracket) Matches() []*BracketMatch {
+	return b.matches
+}
+
+// Current is the next match to decide, or nil when there is a winner
+func (b *Bracket) Current() *BracketMatch {
+	for _, m := range b.matches {
+		if m.Winner == nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// RoundProgress returns which match of the current round is being decided,
+// counting from 1, and how many real matches (not byes) the round has
+func (b *Bracket) RoundProgress() (int, int) {
+	current, total := 0, 0
+	cur := b.Current()
+	for _, m := range b.matches {
+		if m.Round != b.round || m.B == nil {
+			continue
+		}
+		total++
+		if m == cur {
+			current = total
+		}
+	}
+	return current, total
+}
+
+// Done returns true once there is a winner
+func (b *Bracket) Done() bool {
+	return b.Current() == nil
+}
+
+// Winner is the last film standing, or nil if the bracket isn't done
+func (b *Bracket) Winner() *Movie {
+	if !b.Done() {
+		return nil
+	}
+	return b.alive[0]
+}
+
+// Choose decides the current match in favour of winner, which must be in it
+func (b *Bracket) Choose(winner *Movie) error {
+	m := b.Current()
+	if m == nil {
+		return errors.New("the bracket is already decided")
+	}
+	switch winner {
+	case m.A:
+		b.choices = append(b.choices, false)
+	case m.B:
+		b.choices = append(b.choices, true)
+	default:
+		return errors.New("that film isn't in this match")
+	}
+	b.decide(m, winner)
+	return nil
+}
+
+// Undo takes back the last choice
+func (b *Bracket) Undo() bool {
+	if len(b.choices) == 0 {
+		return false
+	}
+	choices := b.choices[:len(b.choices)-1]
+	b.choices = nil
+	b.reset()
+	for _, pickB := range choices {
+		m := b.Current()
+		winner := m.A
+		if pickB {
+			winner = m.B
+		}
+		b.choices = append(b.choices, pickB)
+		b.decide(m, winner)
+	}
+	return true
+}
+
+func (b *Bracket) decide(m *BracketMatch, winner *Movie) {
+	m.Winner = winner
+	if b.Current() == nil || b.Current().Round != b.round {
+		b.finishRound()
+	}
+}
+
+// roundMatches are the matches of the current round
+func (b *Bracket) roundMatches() []*BracketMatch {
+	ret := []*BracketMatch{}
+	for _, m := range b.matches {
+		if m.Round == b.round {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+func (b *Bracket) finishRound() {
+	if b.Mode == BracketSingle || b.playoff {
+		winners := []*Movie{}
+		for _, m := range b.roundMatches() {
+			winners = append(winners, m.Winner)
+		}
+		b.alive = winners
+		if len(b.alive) > 1 {
+			b.nextRound()
+		}
+		return
+	}
+
+	for _, m := range b.roundMatches() {
+		b.wins[m.Winner]++
+	}
+	if b.round < b.swissRounds {
+		b.nextRound()
+		return
+	}
+	// Out of rounds, so the leaders go through to a playoff if there is more
+	// than one of them
+	leaders := []*Movie{}
+	for _, e := range b.standings() {
+		if b.wins[e] == b.wins[b.standings()[0]] {
+			leaders = append(leaders, e)
+		}
+	}
+	b.alive = leaders
+	if len(leaders) > 1 {
+		b.playoff = true
+		b.nextRound()
+	}
+}
+
+// standings are the entrants by Swiss wins, ties staying in seed order
+func (b *Bracket) standings() []*Movie {
+	ret := append([]*Movie{}, b.entrants...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return b.wins[ret[i]] > b.wins[ret[j]]
+	})
+	return ret
+}
+
+func (b *Bracket) nextRound() {
+	b.round++
+	if b.Mode == BracketSingle || b.playoff {
+		for i := 0; i < len(b.alive); i += 2 {
+			m := &BracketMatch{Round: b.round, A: b.alive[i]}
+			if i+1 < len(b.alive) {
+				m.B = b.alive[i+1]
+			} else {
+				m.Winner = m.A
+			}
+			b.matches = append(b.matches, m)
+		}
+		return
+	}
+
+	field := b.standings()
+	// With an odd number, the lowest placed film that hasn't had a bye yet
+	// gets one
+	if len(field)%2 == 1 {
+		bye := len(field) - 1
+		for i := len(field) - 1; i >= 0; i-- {
+			if !b.hadBye[field[i]] {
+				bye = i
+				break
+			}
+		}
+		b.hadBye[field[bye]] = true
+		b.matches = append(b.matches, &BracketMatch{Round: b.round, A: field[bye], Winner: field[bye]})
+		field = append(field[:bye], field[bye+1:]...)
+	}
+	// Pair each film with the next best one it hasn't met yet, if there is one
+	paired := map[*Movie]bool{}
+	for i, a := range field {
+		if paired[a] {
+			continue
+		}
+		opponent := -1
+		for j := i + 1; j < len(field); j++ {
+			if paired[field[j]] {
+				continue
+			}
+			if opponent < 0 {
+				opponent = j
+			}
+			if !b.played[[2]*Movie{a, field[j]}] {
+				opponent = j
+				break
+			}
+		}
+		o := field[opponent]
+		paired[a], paired[o] = true, true
+		b.played[[2]*Movie{a, o}], b.played[[2]*Movie{o, a}] = true, true
+		b.matches = append(b.matches, &BracketMatch{Round: b.round, A: a, B: o})
+	}
+}
+
+// recordBracket keeps the result in the history, the winner as won and every
+// other film as lost
+func (c *Client) recordBracket(b *Bracket) {
+	winner := b.Winner()
+	if winner == nil {
+		return
+	}
+	for _, m := range b.Entrants() {
+		event := HistoryBracketLost
+		if m == winner {
+			event = HistoryBracketWon
+		}
+		c.recordHistory(m.IMDBID, m.Title, m.ReleaseYear, event)
+	}
+}
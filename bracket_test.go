@@ -0,0 +1,135 @@
+package letswatch
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func bracketTestMovies(n int) []*Movie {
+	ret := []*Movie{}
+	for i := 0; i < n; i++ {
+		name := string(rune('A' + i))
+		ret = append(ret, &Movie{Title: name, IMDBID: fmt.Sprintf("tt000000%v", i+1)})
+	}
+	return ret
+}
+
+// pick chooses the named film in the current match
+func pick(t *testing.T, b *Bracket, title string) {
+	t.Helper()
+	m := b.Current()
+	require.NotNil(t, m)
+	winner := m.A
+	if m.B != nil && m.B.Title == title {
+		winner = m.B
+	}
+	require.Equal(t, title, winner.Title)
+	require.NoError(t, b.Choose(winner))
+}
+
+func matchTitles(m *BracketMatch) []string {
+	ret := []string{m.A.Title}
+	if m.B != nil {
+		ret = append(ret, m.B.Title)
+	}
+	return ret
+}
+
+func TestBracketSingle(t *testing.T) {
+	b, err := NewBracket(bracketTestMovies(5), BracketSingle)
+	require.NoError(t, err)
+	// E gets a bye
+	require.Equal(t, []string{"A", "B"}, matchTitles(b.Current()))
+	current, total := b.RoundProgress()
+	require.Equal(t, []int{1, 2}, []int{current, total})
+	pick(t, b, "A")
+	require.Equal(t, []string{"C", "D"}, matchTitles(b.Current()))
+	pick(t, b, "D")
+	require.Equal(t, 2, b.Round())
+	require.Equal(t, []string{"A", "D"}, matchTitles(b.Current()))
+	pick(t, b, "D")
+	require.Equal(t, []string{"D", "E"}, matchTitles(b.Current()))
+	require.Nil(t, b.Winner())
+
+	// Undo goes back across rounds
+	require.True(t, b.Undo())
+	require.Equal(t, 2, b.Round())
+	require.Equal(t, []string{"A", "D"}, matchTitles(b.Current()))
+	pick(t, b, "A")
+	pick(t, b, "E")
+	require.True(t, b.Done())
+	require.Equal(t, "E", b.Winner().Title)
+	require.EqualError(t, b.Choose(b.Winner()), "the bracket is already decided")
+}
+
+func TestBracketSwiss(t *testing.T) {
+	b, err := NewBracket(bracketTestMovies(6), BracketSwiss)
+	require.NoError(t, err)
+	pick(t, b, "A")
+	pick(t, b, "C")
+	pick(t, b, "E")
+	// Winners play winners, and nobody plays the same film twice
+	require.Equal(t, []string{"A", "C"}, matchTitles(b.Current()))
+	pick(t, b, "A")
+	require.Equal(t, []string{"E", "B"}, matchTitles(b.Current()))
+	pick(t, b, "B")
+	pick(t, b, "D")
+	require.Equal(t, []string{"A", "D"}, matchTitles(b.Current()))
+	pick(t, b, "D")
+	pick(t, b, "C")
+	pick(t, b, "E")
+	// Four films on two wins go to a playoff
+	require.True(t, b.playoff)
+	require.Equal(t, []string{"A", "C"}, matchTitles(b.Current()))
+	pick(t, b, "A")
+	pick(t, b, "E")
+	pick(t, b, "E")
+	require.Equal(t, "E", b.Winner().Title)
+}
+
+func TestBracketErrors(t *testing.T) {
+	_, err := NewBracket(bracketTestMovies(1), BracketSingle)
+	require.EqualError(t, err, "a bracket needs at least 2 films")
+	_, err = NewBracket(bracketTestMovies(2), "double")
+	require.EqualError(t, err, "unknown bracket mode: double")
+
+	b, err := NewBracket(bracketTestMovies(2), BracketSingle)
+	require.NoError(t, err)
+	require.False(t, b.Undo())
+	require.EqualError(t, b.Choose(&Movie{Title: "Z"}), "that film isn't in this match")
+}
+
+func TestUIBracket(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	var m tea.Model = newModel(&Client{Store: store}, facetTestMovies())
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	// Nothing is shortlisted, so it is everything listed
+	m, _ = m.Update(keyPress("B"))
+	require.Contains(t, m.View(), "Round 1 · match 1 of 2 · 4 films")
+	require.Contains(t, m.View(), "Audition")
+	m, _ = m.Update(keyPress("2"))
+	m, _ = m.Update(keyPress("u"))
+	m, _ = m.Update(keyPress("1"))
+	m, _ = m.Update(keyPress("1"))
+	require.Contains(t, m.View(), "Round 2 · match 1 of 1")
+	m, _ = m.Update(keyPress("2"))
+	require.Contains(t, m.View(), "Alien wins!")
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m, _ = m.Update(cmd())
+	require.Equal(t, "Alien won the bracket", m.(model).status)
+	require.Equal(t, "Alien", m.(model).list.SelectedItem().(MovieItem).movie.Title)
+
+	h, err := store.Get("tt0078748")
+	require.NoError(t, err)
+	require.True(t, h.Has(HistoryBracketWon))
+	h, err = store.Get("tt4016934")
+	require.NoError(t, err)
+	require.True(t, h.Has(HistoryBracketLost))
+}
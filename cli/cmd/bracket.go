@@ -0,0 +1,54 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"github.com/drewstinnett/letswatch"
+	"github.com/spf13/cobra"
+)
+
+// bracketCmd represents the bracket command
+var bracketCmd = &cobra.Command{
+	Use:   "bracket",
+	Short: "Pick a film with head to head matches",
+	Long: `Run a bracket of "this one or that one?" choices between recommended films, or
+the shortlist, until one is left. The winner, and everything it beat, is kept in
+the history.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		mode, _ := cmd.Flags().GetString("mode")
+		max, _ := cmd.Flags().GetInt("max")
+		movies, err := candidateMovies(cmd, max)
+		cobra.CheckErr(err)
+		stats.TotalItems = len(movies)
+		cobra.CheckErr(lwc.BracketUI(movies, mode))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bracketCmd)
+
+	addRecommendFlags(bracketCmd)
+	bracketCmd.PersistentFlags().Bool("shortlist", false, "Use the shortlist instead of recommendations")
+	bracketCmd.PersistentFlags().String("mode", letswatch.BracketSingle, "Kind of bracket. One of: single, swiss")
+	bracketCmd.PersistentFlags().Int("max", 16, "Most films to put in the bracket, 0 for no limit")
+}
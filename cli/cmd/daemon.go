@@ -0,0 +1,93 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+
+	"github.com/drewstinnett/letswatch"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled jobs from the config",
+	Long: `Run the jobs defined under 'daemon.jobs' in the config on a schedule. Only one
+daemon may run at a time. The result of every run is saved to the state file,
+and failing jobs are retried with an increasing backoff.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		d := mustNewDaemon(cmd)
+		sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		cobra.CheckErr(d.Run(sigCtx))
+	},
+}
+
+// daemonStatusCmd represents the daemon status command
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the last and next runs of the daemon jobs",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		d := mustNewDaemon(cmd)
+		out, err := yaml.Marshal(d.Status())
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+	},
+}
+
+func mustNewDaemon(cmd *cobra.Command) *letswatch.Daemon {
+	meInfo, err := letswatch.NewPersonInfoWithCmd(cmd)
+	cobra.CheckErr(err)
+
+	var jobs []*letswatch.Job
+	cobra.CheckErr(viper.UnmarshalKey("daemon.jobs", &jobs))
+
+	statePath := viper.GetString("daemon.state_file")
+	if statePath == "" {
+		home, err := homedir.Dir()
+		cobra.CheckErr(err)
+		statePath = path.Join(home, ".letswatch-state.json")
+	}
+	statePath, err = homedir.Expand(statePath)
+	cobra.CheckErr(err)
+
+	d, err := letswatch.NewDaemon(jobs, statePath, lwc.DaemonRunners(meInfo))
+	cobra.CheckErr(err)
+	d.Notify = lwc.Notify
+	return d
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStatusCmd)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// dismissCmd represents the dismiss command
+var dismissCmd = &cobra.Command{
+	Use:   "dismiss <imdb-id>...",
+	Short: "Hide films from recommendations, forever or for a while",
+	Long: `Hide films from recommend, pick, ui and supplement. Without --for the film is
+hidden until it's brought back with 'letswatch dismissed undo'. With --for it is
+snoozed, and comes back by itself.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		forS, _ := cmd.Flags().GetString("for")
+		var d time.Duration
+		if forS != "" {
+			d, err = letswatch.ParseSnooze(forS)
+			cobra.CheckErr(err)
+		}
+		for _, id := range args {
+			h, err := lwc.Dismiss(ctx, id, d)
+			cobra.CheckErr(err)
+			stats.TotalItems++
+			if h.DismissedUntil.IsZero() {
+				log.Info().Str("imdb", id).Str("title", h.Title).Msg("Dismissed")
+			} else {
+				log.Info().Str("imdb", id).Str("title", h.Title).Time("until", h.DismissedUntil).Msg("Snoozed")
+			}
+		}
+	},
+}
+
+// dismissedCmd represents the dismissed command
+var dismissedCmd = &cobra.Command{
+	Use:   "dismissed",
+	Short: "List films that are dismissed or snoozed",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		films, err := lwc.Dismissed()
+		cobra.CheckErr(err)
+		stats.TotalItems = len(films)
+		out, err := yaml.Marshal(films)
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+	},
+}
+
+// dismissedUndoCmd represents the dismissed undo command
+var dismissedUndoCmd = &cobra.Command{
+	Use:   "undo <imdb-id>...",
+	Short: "Bring back dismissed or snoozed films",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, id := range args {
+			cobra.CheckErr(lwc.Undismiss(id))
+			stats.TotalItems++
+			log.Info().Str("imdb", id).Msg("Undismissed")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dismissCmd)
+	rootCmd.AddCommand(dismissedCmd)
+	dismissedCmd.AddCommand(dismissedUndoCmd)
+
+	dismissCmd.PersistentFlags().String("for", "", "Snooze for this long instead of dismissing forever, like 90d, 2w or 36h")
+}
@@ -1,14 +1,93 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/drewstinnett/go-letterboxd"
+	"github.com/drewstinnett/letswatch"
 	"github.com/gobwas/glob"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
 )
 
+// addRecommendFlags adds the filter and source flags read by
+// letswatch.GetFilterMiscWithCmd, so every command that recommends films
+// takes the same ones
+func addRecommendFlags(cmd *cobra.Command) {
+	// Filter Flags
+	cmd.PersistentFlags().Int("earliest", 1900, "Earliest release year of a film to recommend")
+	cmd.PersistentFlags().String("language", "", "Original language of the movie")
+	cmd.PersistentFlags().Duration("max-runtime", 0, "Maximum runtime of a movie to recommend")
+	cmd.PersistentFlags().Duration("min-runtime", 15*time.Minute, "Minimum runtime of a movie to recommend")
+	cmd.PersistentFlags().String("ends-by", "", "Only include films that finish by this time of day, like 23:00 or 11pm")
+	cmd.PersistentFlags().String("start", "", "Time of day the film starts, for --ends-by. Defaults to now")
+	cmd.PersistentFlags().Bool("include-watched", false, "Include films you have watched films the list")
+	cmd.PersistentFlags().Bool("only-my-streaming", false, "Only include films that are streaming on your streaming services. This includes your Plex, Jellyfin or Emby servers if configured")
+	cmd.PersistentFlags().Bool("only-not-my-streaming", false, "Only include films that are NOT streaming on your streaming services")
+	cmd.PersistentFlags().StringArray("genre", []string{}, "Only include films that have this genre")
+	cmd.PersistentFlags().StringArray("director", []string{}, "Only include films that have this director")
+	cmd.PersistentFlags().Bool("only-new", false, "Only include films that have not been recommended before")
+
+	// Source Flags
+	cmd.PersistentFlags().BoolP("watchlist", "w", false, "Include the users watchlist as part of the recommendations")
+	cmd.PersistentFlags().Bool("top250", false, "Include the top 250 narrative films as part of the recommendations")
+	cmd.PersistentFlags().StringArray("list", []string{}, "Include the list as part of the recommendations in the format <username>/<list-name>")
+	cmd.PersistentFlags().StringArray("json-list", []string{}, "Include a StevenLu/Radarr style JSON list, from a file or URL, as part of the recommendations")
+}
+
+// candidateMovies are the films for commands that choose between a few, like
+// bracket and vote. They come from the shortlist with --shortlist, otherwise
+// from the first max recommendations with their details filled in
+func candidateMovies(cmd *cobra.Command, max int) ([]*letswatch.Movie, error) {
+	var movies []*letswatch.Movie
+	if fromShortlist, _ := cmd.Flags().GetBool("shortlist"); fromShortlist {
+		var err error
+		if movies, err = lwc.Shortlist(); err != nil {
+			return nil, err
+		}
+	} else {
+		meInfo, movieFilterOpts, movieCollectOpts, err := letswatch.GetFilterMiscWithCmd(cmd)
+		if err != nil {
+			return nil, err
+		}
+		// The stream is stopped once there are enough, so the rest aren't
+		// looked up or recorded as recommended
+		parent := ctx
+		if parent == nil {
+			parent = context.Background()
+		}
+		streamCtx, cancel := context.WithCancel(parent)
+		defer cancel()
+		movieC := make(chan *letswatch.Movie)
+		errC := make(chan error, 1)
+		go func() {
+			errC <- lwc.StreamRecommendations(streamCtx, meInfo, movieFilterOpts, movieCollectOpts, movieC)
+		}()
+		for movie := range movieC {
+			// Keep draining so the stream can finish
+			if max > 0 && len(movies) >= max {
+				continue
+			}
+			lwc.FillDetails(parent, movie)
+			movies = append(movies, movie)
+			if max > 0 && len(movies) >= max {
+				cancel()
+			}
+		}
+		if err := <-errC; err != nil && !(errors.Is(err, context.Canceled) && parent.Err() == nil) {
+			return nil, err
+		}
+		lwc.RecordRecommended(movies...)
+	}
+	if max > 0 && len(movies) > max {
+		movies = movies[:max]
+	}
+	return movies, nil
+}
+
 // Given a slice of strings, return a slice of ListIDs
 func parseListArgs(args []string) ([]*letterboxd.ListID, error) {
 	var ret []*letterboxd.ListID
@@ -2,8 +2,12 @@ package cmd
 
 import (
 	"testing"
+	"time"
 
 	"github.com/drewstinnett/go-letterboxd"
+	"github.com/drewstinnett/letswatch"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 )
 
@@ -103,3 +107,43 @@ func TestContainsString(t *testing.T) {
 		require.Equal(t, test.r, got)
 	}
 }
+
+func TestAddRecommendFlags(t *testing.T) {
+	// ui takes every filter and source flag that recommend does
+	for _, name := range []string{
+		"earliest", "language", "max-runtime", "min-runtime", "include-watched", "only-my-streaming",
+		"only-not-my-streaming", "genre", "director", "only-new", "ends-by", "start", "watchlist", "top250", "list", "json-list",
+	} {
+		uf := uiCmd.PersistentFlags().Lookup(name)
+		require.NotNil(t, uf, name)
+		require.Equal(t, recommendCmd.PersistentFlags().Lookup(name).DefValue, uf.DefValue, name)
+	}
+
+	viper.Set("letterboxd-username", "someone")
+	defer viper.Set("letterboxd-username", "")
+	cmd := &cobra.Command{}
+	addRecommendFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--watchlist", "--genre", "Horror", "--max-runtime", "2h", "--list", "dave/top-250"}))
+	_, filter, collect, err := letswatch.GetFilterMiscWithCmd(cmd)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Horror"}, filter.Genres)
+	require.Equal(t, 2*time.Hour, filter.MaxRuntime)
+	require.Equal(t, 15*time.Minute, filter.MinRuntime)
+	require.True(t, collect.Watchlist)
+	require.Equal(t, []*letterboxd.ListID{{User: "dave", Slug: "top-250"}}, collect.Lists)
+
+	// Finishing in time can only lower the max runtime
+	cmd = &cobra.Command{}
+	addRecommendFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--start", "20:15", "--ends-by", "23:00", "--max-runtime", "4h"}))
+	_, filter, _, err = letswatch.GetFilterMiscWithCmd(cmd)
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Hour+45*time.Minute, filter.MaxRuntime)
+
+	// Filters are checked against the person's subscriptions
+	cmd = &cobra.Command{}
+	addRecommendFlags(cmd)
+	require.NoError(t, cmd.ParseFlags([]string{"--only-my-streaming"}))
+	_, _, _, err = letswatch.GetFilterMiscWithCmd(cmd)
+	require.EqualError(t, err, "You must have at least one subscribed to to use only-my-streaming")
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history [imdb-id...]",
+	Short: "Show when films were recommended, picked, added or marked not interested",
+	Run: func(cmd *cobra.Command, args []string) {
+		event, _ := cmd.Flags().GetString("event")
+
+		films, err := lwc.History(args...)
+		cobra.CheckErr(err)
+
+		ret := []*letswatch.FilmHistory{}
+		for _, h := range films {
+			if event == "" || h.Has(event) {
+				ret = append(ret, h)
+			}
+		}
+		stats.TotalItems = len(ret)
+		out, err := yaml.Marshal(ret)
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.PersistentFlags().String("event", "", "Only show films with this event. One of: recommended, picked, added, not-interested, bracket-won, bracket-lost")
+}
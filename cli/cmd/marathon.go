@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// marathonView is how a plan is shown by the marathon command
+type marathonView struct {
+	Films   []marathonFilm `yaml:"films"`
+	Runtime string         `yaml:"runtime"`
+	Total   string         `yaml:"total"`
+	Spare   string         `yaml:"spare"`
+	Theme   string         `yaml:"theme,omitempty"`
+}
+
+type marathonFilm struct {
+	Title     string   `yaml:"title"`
+	Year      int      `yaml:"year,omitempty"`
+	Runtime   string   `yaml:"runtime"`
+	Starts    string   `yaml:"starts,omitempty"`
+	Directors []string `yaml:"directors,omitempty"`
+	Genres    []string `yaml:"genres,omitempty"`
+	IMDBID    string   `yaml:"imdb_id,omitempty"`
+}
+
+// marathonCmd represents the marathon command
+var marathonCmd = &cobra.Command{
+	Use:   "marathon",
+	Short: "Plan a double feature, or more, that fits in the time there is",
+	Long: `Pack recommended films, or the shortlist, in to a time budget. The budget is
+--budget, or the time between --start (or now) and --ends-by. Plans are best
+first, by rating with a bump for films on your services or in your library.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		budget, _ := cmd.Flags().GetDuration("budget")
+		count, _ := cmd.Flags().GetInt("count")
+		breakTime, _ := cmd.Flags().GetDuration("break")
+		constraint, _ := cmd.Flags().GetString("constraint")
+		plans, _ := cmd.Flags().GetInt("plans")
+		max, _ := cmd.Flags().GetInt("max")
+		start, _ := cmd.Flags().GetString("start")
+		endsBy, _ := cmd.Flags().GetString("ends-by")
+
+		if endsBy != "" {
+			allowed, err := letswatch.RuntimeUntil(start, endsBy, time.Now())
+			cobra.CheckErr(err)
+			if budget == 0 || allowed < budget {
+				budget = allowed
+			}
+		}
+		if budget == 0 {
+			cobra.CheckErr(errors.New("set a --budget, or --ends-by"))
+		}
+
+		movies, err := candidateMovies(cmd, max)
+		cobra.CheckErr(err)
+		marathons, err := letswatch.PlanMarathons(movies, letswatch.MarathonOpts{
+			Budget:     budget,
+			Count:      count,
+			Break:      breakTime,
+			Constraint: constraint,
+			Plans:      plans,
+		})
+		cobra.CheckErr(err)
+
+		// Start times are only shown when there is a time of day to go on
+		var startAt time.Time
+		if start != "" || endsBy != "" {
+			startAt, err = letswatch.StartTime(start, time.Now())
+			cobra.CheckErr(err)
+		}
+
+		views := []marathonView{}
+		for _, m := range marathons {
+			view := marathonView{
+				Runtime: fmt.Sprint(m.Runtime),
+				Total:   fmt.Sprint(m.Total),
+				Spare:   fmt.Sprint(m.Spare),
+				Theme:   m.Theme,
+			}
+			at := startAt
+			for _, f := range m.Films {
+				film := marathonFilm{
+					Title:     f.Title,
+					Year:      f.ReleaseYear,
+					Runtime:   fmt.Sprint(f.RunTime),
+					Directors: f.Directors,
+					Genres:    f.Genres,
+					IMDBID:    f.IMDBID,
+				}
+				if !at.IsZero() {
+					film.Starts = at.Format("15:04")
+					at = at.Add(f.RunTime + breakTime)
+				}
+				view.Films = append(view.Films, film)
+			}
+			views = append(views, view)
+		}
+		stats.TotalItems = len(views)
+		out, err := yaml.Marshal(views)
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(marathonCmd)
+
+	addRecommendFlags(marathonCmd)
+	marathonCmd.PersistentFlags().Bool("shortlist", false, "Use the shortlist instead of recommendations")
+	marathonCmd.PersistentFlags().Duration("budget", 0, "Time there is for the whole marathon, breaks included")
+	marathonCmd.PersistentFlags().Int("count", 2, "Number of films to watch")
+	marathonCmd.PersistentFlags().Duration("break", 0, "Break between films")
+	marathonCmd.PersistentFlags().String("constraint", "", "What the films should have in common. One of: same-director, same-theme, different-genres")
+	marathonCmd.PersistentFlags().Int("plans", 3, "Number of plans to show")
+	marathonCmd.PersistentFlags().Int("max", 40, "Most recommendations to plan with, 0 for no limit")
+}
@@ -0,0 +1,159 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// queueEntry is how the shortlist is shown by the queue command
+type queueEntry struct {
+	Position int    `yaml:"position"`
+	Title    string `yaml:"title"`
+	Year     int    `yaml:"year,omitempty"`
+	IMDBID   string `yaml:"imdb_id,omitempty"`
+	TMDBID   string `yaml:"tmdb_id,omitempty"`
+}
+
+func printQueue(movies []*letswatch.Movie) {
+	entries := []queueEntry{}
+	for i, m := range movies {
+		entries = append(entries, queueEntry{
+			Position: i + 1,
+			Title:    m.Title,
+			Year:     m.ReleaseYear,
+			IMDBID:   m.IMDBID,
+			TMDBID:   m.TMDBID,
+		})
+	}
+	stats.TotalItems = len(entries)
+	out, err := yaml.Marshal(entries)
+	cobra.CheckErr(err)
+	fmt.Print(string(out))
+}
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Show the shortlist of films to pick from",
+	Long: `Show the shortlist built with 's' in 'letswatch ui', in order. The subcommands
+add, remove, reorder and export it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		movies, err := lwc.Shortlist()
+		cobra.CheckErr(err)
+		printQueue(movies)
+	},
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add <imdb-id>...",
+	Short: "Add films to the end of the shortlist",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		movies, err := lwc.ShortlistAdd(ctx, args...)
+		cobra.CheckErr(err)
+		printQueue(movies)
+	},
+}
+
+var queueRemoveCmd = &cobra.Command{
+	Use:     "remove <imdb-id>...",
+	Aliases: []string{"rm"},
+	Short:   "Take films off the shortlist",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		movies, err := lwc.ShortlistRemove(args...)
+		cobra.CheckErr(err)
+		printQueue(movies)
+	},
+}
+
+var queueMoveCmd = &cobra.Command{
+	Use:   "move <imdb-id> <position>",
+	Short: "Move a film to a new position on the shortlist, counting from 1",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		position, err := strconv.Atoi(args[1])
+		cobra.CheckErr(err)
+		movies, err := lwc.ShortlistMove(args[0], position)
+		cobra.CheckErr(err)
+		printQueue(movies)
+	},
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Empty the shortlist",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(lwc.SaveShortlist(nil))
+		log.Info().Msg("Cleared the shortlist")
+	},
+}
+
+var queueExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the shortlist as a Letterboxd import CSV or JSON",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		movies, err := lwc.Shortlist()
+		cobra.CheckErr(err)
+		stats.TotalItems = len(movies)
+		cobra.CheckErr(letswatch.WriteShortlist(os.Stdout, movies, format))
+	},
+}
+
+var queueToPlexCmd = &cobra.Command{
+	Use:   "to-plex <playlist>",
+	Short: "Put the shortlisted films that are in Plex on a playlist, in order",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		modeS, _ := cmd.Flags().GetString("plex-list-mode")
+		mode, err := letswatch.ParsePlexListMode(modeS)
+		cobra.CheckErr(err)
+		n, err := lwc.ShortlistToPlexPlaylist(ctx, args[0], mode)
+		cobra.CheckErr(err)
+		stats.TotalItems = n
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueAddCmd)
+	queueCmd.AddCommand(queueRemoveCmd)
+	queueCmd.AddCommand(queueMoveCmd)
+	queueCmd.AddCommand(queueClearCmd)
+	queueCmd.AddCommand(queueExportCmd)
+	queueCmd.AddCommand(queueToPlexCmd)
+
+	queueExportCmd.PersistentFlags().StringP("format", "f", letswatch.ShortlistFormatLetterboxdCSV, "Output format. One of: letterboxd-csv, json")
+	queueToPlexCmd.PersistentFlags().String("plex-list-mode", string(letswatch.PlexListReplace), "How to update an existing Plex playlist. One of: replace, append")
+}
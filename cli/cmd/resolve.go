@@ -0,0 +1,73 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveCmd represents the resolve command
+var resolveCmd = &cobra.Command{
+	Use:   "resolve <title>",
+	Short: "Find a film on TMDB by its title, showing how confident each match is",
+	Long: `Search TMDB for a film with only a title, like one from a local file or a Plex
+library, and score what comes back on the title, original and alternative
+titles, year and directors. Below --threshold the film is reported as
+unresolved rather than matched to the wrong thing.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		year, _ := cmd.Flags().GetInt("year")
+		directors, _ := cmd.Flags().GetStringArray("director")
+		if threshold, _ := cmd.Flags().GetFloat64("threshold"); threshold > 0 {
+			lwc.Config.MatchThreshold = threshold
+		}
+		ref := letswatch.FilmRef{Title: strings.Join(args, " "), Year: year, Directors: directors}
+		matches, err := lwc.MatchTitle(ctx, ref)
+		cobra.CheckErr(err)
+		stats.TotalItems = len(matches)
+		out, err := yaml.Marshal(matches)
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+
+		// Matches are best first, so only the first needs to clear the threshold
+		if len(matches) == 0 || matches[0].Confidence < lwc.MatchThreshold() {
+			log.Warn().Str("film", ref.String()).Float64("threshold", lwc.MatchThreshold()).Msg("Unresolved")
+			return
+		}
+		match := matches[0]
+		log.Info().Str("film", ref.String()).Int64("tmdb", match.TMDBID).Float64("confidence", match.Confidence).Msg("Resolved")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resolveCmd)
+
+	resolveCmd.PersistentFlags().Int("year", 0, "Year the film came out")
+	resolveCmd.PersistentFlags().StringArray("director", []string{}, "Director of the film, may be given more than once")
+	resolveCmd.PersistentFlags().Float64("threshold", 0, fmt.Sprintf("Confidence needed to resolve the film, from 0 to 1 (default %v, or match_threshold in the config)", letswatch.DefaultMatchThreshold))
+}
@@ -50,6 +50,10 @@ var (
 type runStats struct {
 	TotalItems int           `json:"total_items"`
 	Duration   time.Duration `json:"duration"`
+	// Retries are the calls retried, by service
+	Retries map[string]int `json:"retries,omitempty"`
+	// Unavailable are the services that were given up on
+	Unavailable []string `json:"unavailable,omitempty"`
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -67,10 +71,29 @@ var rootCmd = &cobra.Command{
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
 		end := time.Now()
 		stats.Duration = end.Sub(start)
-		log.Info().Int("total_items", stats.TotalItems).Str("duration", fmt.Sprint(stats.Duration)).Msg("Run stats")
+		slog := log.Info().Int("total_items", stats.TotalItems).Str("duration", fmt.Sprint(stats.Duration))
+		if lwc != nil && lwc.Resilience != nil {
+			stats.Retries = lwc.Resilience.Retries()
+			stats.Unavailable = lwc.Resilience.Unavailable()
+			if len(stats.Retries) > 0 {
+				slog = slog.Interface("retries", stats.Retries)
+			}
+			if len(stats.Unavailable) > 0 {
+				slog = slog.Strs("unavailable", stats.Unavailable)
+			}
+		}
+		slog.Msg("Run stats")
 	},
 }
 
+// checkErrNotify is cobra.CheckErr, but sends a run-failed notification first
+func checkErrNotify(name string, err error) {
+	if err != nil && lwc != nil && lwc.Notify != nil {
+		_ = lwc.Notify.Send(ctx, letswatch.NewRunFailedNotification(name, err))
+	}
+	cobra.CheckErr(err)
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -94,6 +117,10 @@ func init() {
 	viper.BindPFlag("letterboxd-username", rootCmd.PersistentFlags().Lookup("letterboxd-username"))
 	rootCmd.PersistentFlags().StringArray("subscribed-to", []string{}, "Streaming services that you are subscribed to")
 	viper.BindPFlag("subscribed-to", rootCmd.PersistentFlags().Lookup("subscribed-to"))
+	rootCmd.PersistentFlags().Bool("plex-watched", false, "Treat movies in the Plex play history as watched")
+	viper.BindPFlag("plex-watched", rootCmd.PersistentFlags().Lookup("plex-watched"))
+	rootCmd.PersistentFlags().StringArray("plex-watched-users", []string{}, "Plex users (including managed users) whose history counts as watched. Defaults to everyone")
+	viper.BindPFlag("plex-watched-users", rootCmd.PersistentFlags().Lookup("plex-watched-users"))
 	rootCmd.PersistentFlags().String("redis-host", "localhost:6379", "URL for Redis cluster")
 	viper.BindPFlag("redis-host", rootCmd.PersistentFlags().Lookup("redis-host"))
 }
@@ -124,31 +151,3 @@ func initConfig() {
 		log.Debug().Str("config-file", viper.ConfigFileUsed()).Msg("Using config file")
 	}
 }
-
-// Remove dups from slice.
-func removeDups(elements []string) (nodups []string) {
-	encountered := make(map[string]bool)
-	for _, element := range elements {
-		if !encountered[element] {
-			nodups = append(nodups, element)
-			encountered[element] = true
-		}
-	}
-	return
-}
-
-func intersection(s1, s2 []string) (inter []string) {
-	hash := make(map[string]bool)
-	for _, e := range s1 {
-		hash[e] = true
-	}
-	for _, e := range s2 {
-		// If elements present in the hashmap then append intersection list.
-		if hash[e] {
-			inter = append(inter, e)
-		}
-	}
-	// Remove dups from slice.
-	inter = removeDups(inter)
-	return
-}
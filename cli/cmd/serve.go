@@ -0,0 +1,86 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve recommendations over a JSON HTTP API",
+	Long: `Run an HTTP server exposing recommend, pick, film lookups and the supplement
+plan as JSON endpoints. A single client, and its cache, is shared across all
+requests. See the README for the endpoints and response schema.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		meInfo, err := letswatch.NewPersonInfoWithCmd(cmd)
+		cobra.CheckErr(err)
+		addr, _ := cmd.Flags().GetString("addr")
+		shutdownTimeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           letswatch.NewServer(lwc, meInfo),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		errC := make(chan error, 1)
+		go func() {
+			log.Info().Str("addr", addr).Msg("Serving")
+			errC <- srv.ListenAndServe()
+		}()
+
+		select {
+		case err := <-errC:
+			if !errors.Is(err, http.ErrServerClosed) {
+				cobra.CheckErr(err)
+			}
+		case <-sigCtx.Done():
+			log.Info().Msg("Shutting down")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			cobra.CheckErr(srv.Shutdown(shutdownCtx))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.PersistentFlags().String("addr", ":8080", "Address to listen on")
+	serveCmd.PersistentFlags().Duration("shutdown-timeout", 30*time.Second, "How long to wait for in flight requests when shutting down")
+}
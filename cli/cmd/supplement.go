@@ -22,16 +22,18 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"github.com/drewstinnett/go-letterboxd"
+	"fmt"
+
 	"github.com/drewstinnett/letswatch"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-	"golift.io/starr/radarr"
+	"github.com/spf13/viper"
 )
 
 var (
 	matchGlobs []string
 	listsA     []string
+	jsonListsA []string
 	dryRun     bool
 )
 
@@ -39,49 +41,39 @@ var (
 var supplementCmd = &cobra.Command{
 	Use:   "supplement",
 	Short: "Supplement your streaming content with missing films",
-	Long:  `Get a list of moves we can't find streaming, and send them in to another API for requests.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		isoBatchFilter := &letterboxd.FilmBatchOpts{
-			List: mustParseListArgs(listsA),
-		}
-		// Pull in films
-		isoC := make(chan *letterboxd.Film)
-		done := make(chan error)
-		go lwc.LetterboxdClient.Film.StreamBatch(ctx, isoBatchFilter, isoC, done)
-		isoFilms, err := letterboxd.SlurpFilms(isoC, done)
-		cobra.CheckErr(err)
+	Long: `Get a list of moves we can't find streaming, and send them in to another API for requests.
 
-		log.Info().Msg("Pruning film list")
-		prunedFilms, err := lwc.PruneFilms(isoFilms, letswatch.PruneOpts{
-			RemoveTitleGlobs:  matchGlobs,
-			RemoveWatched:     true,
-			RemoveMyStreaming: true,
-			RemoveMyPlex:      true,
-			RemoveMyRadarr:    true,
-		})
+The request backend is chosen with 'request_backend' in the config, and may be
+'radarr' (the default), 'overseerr' or 'jellyseerr'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		meInfo, err := letswatch.NewPersonInfoWithCmd(cmd)
 		cobra.CheckErr(err)
+		prunedFilms, err := lwc.SupplementPlan(ctx, meInfo, &letswatch.MovieCollectOpts{
+			Lists:     mustParseListArgs(listsA),
+			JSONLists: jsonListsA,
+		}, matchGlobs)
+		checkErrNotify("supplement", err)
 		stats.TotalItems = len(prunedFilms)
 
-		moviesToAdd := []radarr.AddMovieInput{}
-
-		for _, item := range prunedFilms {
-
-			mi, err := lwc.Radarr.MovieInputWithLetterboxdFilm(item)
-			cobra.CheckErr(err)
-			moviesToAdd = append(moviesToAdd, *mi)
+		backend := viper.GetString("request_backend")
+		if backend == "" {
+			backend = letswatch.RequestBackendRadarr
 		}
-
-		if !dryRun {
-			for _, mi := range moviesToAdd {
-				log.Info().Str("title", mi.Title).Int("year", mi.Year).Msg("Adding to radarr")
-				_, err = lwc.Radarr.AddMovie(&mi)
-				cobra.CheckErr(err)
-			}
-		} else {
-			for _, mi := range moviesToAdd {
-				log.Info().Str("movie", mi.Title).Int("year", mi.Year).Msg("Dry run, not adding to radarr")
+		if dryRun {
+			for _, item := range prunedFilms {
+				log.Info().Str("movie", item.Title).Int("year", item.Year).Str("backend", backend).Msg("Dry run, not requesting")
 			}
+			return
+		}
+		requested, err := lwc.RequestFilms(ctx, prunedFilms)
+		if len(requested) > 0 {
+			_ = lwc.Notify.Send(ctx, letswatch.NewFilmsNotification(
+				letswatch.EventFilmsAdded,
+				fmt.Sprintf("Requested %v films from %v", len(requested), backend),
+				requested,
+			))
 		}
+		checkErrNotify("supplement", err)
 	},
 }
 
@@ -94,7 +86,8 @@ func init() {
 	// and all subcommands, e.g.:
 	// supplementCmd.PersistentFlags().String("foo", "", "A help for foo")
 	supplementCmd.PersistentFlags().StringArrayVar(&listsA, "list", []string{}, "Include the list as part of the recommendations in the format <username>/<list-name>")
-	supplementCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Don't actually add anything to radarr")
+	supplementCmd.PersistentFlags().StringArrayVar(&jsonListsA, "json-list", []string{}, "Include a StevenLu/Radarr style JSON list, from a file or URL")
+	supplementCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Don't actually request anything")
 	supplementCmd.PersistentFlags().StringArrayVar(&matchGlobs, "match-globs", []string{}, "Only recommend movies matching these globs")
 
 	// Cobra supports local flags which will only run when this command
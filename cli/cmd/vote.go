@@ -0,0 +1,151 @@
+/*
+Copyright © 2022 Drew Stinnett <drew@drewlink.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/drewstinnett/letswatch"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ballotView is how the open vote is shown by the vote command
+type ballotView struct {
+	Candidates []queueEntry `yaml:"candidates"`
+	Voters     []string     `yaml:"voters,omitempty"`
+}
+
+func printBallot(candidates []*letswatch.Movie, voters []string) {
+	view := ballotView{Voters: voters}
+	for i, m := range candidates {
+		view.Candidates = append(view.Candidates, queueEntry{
+			Position: i + 1,
+			Title:    m.Title,
+			Year:     m.ReleaseYear,
+			IMDBID:   m.IMDBID,
+			TMDBID:   m.TMDBID,
+		})
+	}
+	stats.TotalItems = len(view.Candidates)
+	out, err := yaml.Marshal(view)
+	cobra.CheckErr(err)
+	fmt.Print(string(out))
+}
+
+// voteCmd represents the vote command
+var voteCmd = &cobra.Command{
+	Use:   "vote",
+	Short: "Show the open ranked choice vote",
+	Long: `Vote on what to watch with ranked choices. 'vote open' puts a few films on a
+ballot, everyone ranks them with 'vote cast', here or through 'letswatch serve',
+and 'vote tally' counts them.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := lwc.Ballot()
+		cobra.CheckErr(err)
+		printBallot(b.Candidates, b.Voters())
+	},
+}
+
+var voteOpenCmd = &cobra.Command{
+	Use:   "open",
+	Short: "Start a new vote between recommended films, or the shortlist",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		max, _ := cmd.Flags().GetInt("max")
+		movies, err := candidateMovies(cmd, max)
+		cobra.CheckErr(err)
+		b, err := lwc.OpenVote(movies)
+		cobra.CheckErr(err)
+		printBallot(b.Candidates, nil)
+	},
+}
+
+var voteCastCmd = &cobra.Command{
+	Use:   "cast <voter> <film>...",
+	Short: "Rank the films on the ballot, favourite first",
+	Long: `Rank the films on the ballot, favourite first. Films are given by their number
+on the ballot, or IMDB or TMDB ID. Any left off are ranked below the rest.
+Voting again replaces the earlier vote.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		server, _ := cmd.Flags().GetString("server")
+		if server == "" {
+			b, err := lwc.CastVote(args[0], args[1:])
+			cobra.CheckErr(err)
+			printBallot(b.Candidates, b.Voters())
+			return
+		}
+
+		body, err := json.Marshal(&letswatch.VoteRequest{Voter: args[0], Ranking: args[1:]})
+		cobra.CheckErr(err)
+		res, err := http.Post(strings.TrimSuffix(server, "/")+"/v1/vote", "application/json", bytes.NewReader(body))
+		cobra.CheckErr(err)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			var e letswatch.ErrorResponse
+			if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+				e.Error = res.Status
+			}
+			cobra.CheckErr(fmt.Errorf("casting vote: %v", e.Error))
+		}
+		var b letswatch.BallotResponse
+		cobra.CheckErr(json.NewDecoder(res.Body).Decode(&b))
+		printBallot(b.Candidates, b.Voters)
+	},
+}
+
+var voteTallyCmd = &cobra.Command{
+	Use:   "tally",
+	Short: "Count the votes, showing each round",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		method, _ := cmd.Flags().GetString("method")
+		b, err := lwc.Ballot()
+		cobra.CheckErr(err)
+		res, err := b.Tally(method)
+		cobra.CheckErr(err)
+		out, err := yaml.Marshal(res)
+		cobra.CheckErr(err)
+		fmt.Print(string(out))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(voteCmd)
+	voteCmd.AddCommand(voteOpenCmd)
+	voteCmd.AddCommand(voteCastCmd)
+	voteCmd.AddCommand(voteTallyCmd)
+
+	addRecommendFlags(voteOpenCmd)
+	voteOpenCmd.PersistentFlags().Bool("shortlist", false, "Use the shortlist instead of recommendations")
+	voteOpenCmd.PersistentFlags().Int("max", 5, "Most films to put on the ballot, 0 for no limit")
+
+	voteCastCmd.PersistentFlags().String("server", "", "Cast the vote through a 'letswatch serve' at this URL, instead of the local ballot")
+
+	voteTallyCmd.PersistentFlags().String("method", letswatch.TallyInstantRunoff, "How to count the votes. One of: irv, schulze")
+}
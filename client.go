@@ -2,16 +2,19 @@ package letswatch
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
-	tmdb "github.com/cyruzin/golang-tmdb"
 	"github.com/drewstinnett/go-letterboxd"
 	"github.com/go-redis/cache/v8"
 	"github.com/go-redis/redis/v8"
 	"github.com/jrudio/go-plex-client"
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golift.io/starr"
 	"golift.io/starr/radarr"
@@ -22,10 +25,25 @@ type Client struct {
 	LetterboxdClient *letterboxd.Client
 	HTTPClient       *http.Client
 	Cache            *cache.Cache
+	// Resilience retries calls to the services below, and stops calling ones
+	// that are down
+	Resilience *Resilience
 	// Service Clients
-	Plex      PlexService
-	TMDB      TMDBService
-	Radarr    RadarrService
+	Plex PlexService
+	// MediaServers are all the configured libraries, Plex included
+	MediaServers []MediaServer
+	TMDB         TMDBService
+	Radarr       RadarrService
+	Overseerr    OverseerrService
+	// Requester is whichever backend new film requests are sent to
+	Requester Requester
+	// Notify sends events to the configured notifiers
+	Notify NotifyService
+	// Store keeps film history between runs. It is nil if no StorePath is
+	// configured
+	Store Store
+	// Posters fetches film posters for the UI
+	Posters   PosterService
 	UserAgent string
 	Config    *ClientConfig
 }
@@ -37,73 +55,104 @@ type ClientConfig struct {
 	TMDBKey          string
 	PlexURL          string
 	PlexToken        string
+	PlexWatched      bool
+	PlexWatchedUsers []string
+	JellyfinURL      string
+	JellyfinKey      string
+	EmbyURL          string
+	EmbyKey          string
 	RadarrURL        string
 	RadarrKey        string
 	RadarrQuality    string
 	RadarrPath       string
+	OverseerrURL     string
+	OverseerrKey     string
+	RequestBackend   string
+	Notifiers        []NotifierConfig
+	StorePath        string
+	BallotPath       string
+	// MatchThreshold is the confidence a title search needs, defaulting to
+	// DefaultMatchThreshold
+	MatchThreshold float64
+	// RetryPolicies override DefaultRetryPolicy by service
+	RetryPolicies    map[string]RetryPolicy
+	PosterCacheDir   string
+	PosterProtocol   string
 	LetterboxdConfig *letterboxd.ClientConfig
+	// JSONListHosts are the only hosts serve fetches JSON lists from. Any
+	// host is allowed if it is empty
+	JSONListHosts []string
 }
 
-func (c *Client) PruneFilms(films []*letterboxd.Film, popt PruneOpts) ([]*letterboxd.Film, error) {
-	var matchesGlob bool
-	var err error
+func (c *Client) PruneFilms(ctx context.Context, films []*letterboxd.Film, popt PruneOpts) ([]*letterboxd.Film, error) {
+	titleGlobs, err := CompileGlobs(popt.RemoveTitleGlobs)
+	if err != nil {
+		return nil, err
+	}
 	meInfo, err := NewPersonInfoWithViper(viper.GetViper())
 	if err != nil {
 		return nil, err
 	}
 
 	// Only get watched IDs if we need to
-	watchedIDs := []string{}
+	watched := WatchedSet{}
 	ret := []*letterboxd.Film{}
 	if popt.RemoveWatched {
 		log.Info().Msg("Fetching watched in order to prune based on them later")
-		watchedIDs, err = c.LetterboxdClient.Film.GetWatchedIMDBIDs(context.TODO(), meInfo.LetterboxdUsername)
+		watched, err = c.Watched(ctx, meInfo.LetterboxdUsername)
 		if err != nil {
 			return nil, err
 		}
 	}
 	log.Info().Int("unpruned", len(films)).Msg("Film list")
 	for _, f := range films {
+		ref := FilmRefWithLetterboxd(f)
 		slog := log.With().
 			Str("film", f.Title).
-			Str("imdb", f.ExternalIDs.IMDB).
-			Str("tmdb", f.ExternalIDs.TMDB).
+			Str("imdb", ref.IMDBID).
+			Str("tmdb", ref.TMDBID).
 			Logger()
 		// Are we matching title glob removals?
-		if len(popt.RemoveTitleGlobs) > 0 {
-			if matches := MatchesGlobOf(f.Title, popt.RemoveTitleGlobs); !matches {
-				slog.Debug().Msg("Removing because film matches glob")
-				matchesGlob = true
-				continue
-			}
-		}
-		if matchesGlob {
+		if len(titleGlobs) > 0 && !MatchesGlobOf(f.Title, titleGlobs) {
+			slog.Debug().Msg("Removing because film matches no glob")
 			continue
 		}
 
-		// Remove Watched films if asked
-		if popt.RemoveWatched {
-			if ContainsString(watchedIDs, f.ExternalIDs.IMDB) {
-				slog.Debug().Msg("Already watched")
-				continue
+		// skip is for dismissed films, and watched ones if asked
+		skip := func(imdbID string) bool {
+			if c.IsDismissed(imdbID) {
+				slog.Debug().Msg("Dismissed")
+				return true
+			}
+			if popt.RemoveWatched && watched.Contains(imdbID) {
+				slog.Debug().Strs("sources", watched.Sources(imdbID)).Msg("Already watched")
+				return true
 			}
+			return false
+		}
+		if ref.IMDBID != "" && skip(ref.IMDBID) {
+			continue
 		}
 
-		// Get TMDB stuff
-		var m *tmdb.MovieDetails
-		if f.ExternalIDs.IMDB != "" {
-			m, err = c.TMDB.GetWithIMDBID(context.TODO(), f.ExternalIDs.IMDB)
-			if err != nil {
-				slog.Warn().Err(err).Msg("Error getting movie from TMDB")
+		// Get TMDB stuff, by whichever ID the film has
+		m, err := c.ResolveFilm(ctx, ref)
+		if err != nil {
+			slog.Warn().Err(err).Msg("Error getting movie from TMDB")
+			continue
+		}
+		// Fill in the IDs the list didn't have, so they can be checked, and
+		// used by whatever the films are for
+		if f.ExternalIDs == nil {
+			f.ExternalIDs = &letterboxd.FilmExternalIDs{}
+		}
+		if f.ExternalIDs.TMDB == "" {
+			f.ExternalIDs.TMDB = fmt.Sprint(m.ID)
+		}
+		if f.ExternalIDs.IMDB == "" && m.IMDbID != "" {
+			f.ExternalIDs.IMDB = m.IMDbID
+			if skip(m.IMDbID) {
 				continue
 			}
-		} else {
-			log.Debug().Err(err).Msg("Movie does not have an IMDB entry. Skipping...")
-		}
-		// Skip if no TMDB data
-		if m == nil {
-			log.Warn().Err(err).Msg("No TMDB data for film")
-			continue
 		}
 
 		// Remove if in my streaming?
@@ -121,36 +170,141 @@ func (c *Client) PruneFilms(films []*letterboxd.Film, popt PruneOpts) ([]*letter
 		}
 
 		// Do we care about Plex?
-		if popt.RemoveMyPlex {
-			isAvailOnPlex, err := c.Plex.IsAvailable(context.TODO(), f.Title, f.Year)
-			cobra.CheckErr(err)
+		if popt.RemoveMyPlex && c.Plex != nil {
+			// A Plex that is down shouldn't stop the run, the film is kept
+			isAvailOnPlex, err := c.Plex.IsAvailable(ctx, f.Title, f.Year)
+			if err != nil {
+				slog.Warn().Err(err).Msg("Error checking Plex, keeping the film")
+			}
 			if isAvailOnPlex {
 				slog.Debug().Msg("Film is available on Plex, skipping")
 				continue
 			}
 		}
 
+		// Or any of my media servers
+		if popt.RemoveMyMediaServers {
+			availableOn := c.AvailableOn(ctx, f.Title, f.Year)
+			if len(availableOn) > 0 {
+				slog.Debug().Strs("servers", availableOn).Msg("Film is available on my media servers, skipping")
+				continue
+			}
+		}
+
 		if popt.RemoveMyRadarr {
 			results, err := c.Radarr.MoviesWithTMDBID(m.ID)
-			cobra.CheckErr(err)
+			if err != nil {
+				slog.Warn().Err(err).Msg("Error checking Radarr, keeping the film")
+			}
 			if len(results) > 0 {
 				slog.Debug().Msg("Film already in radarr")
 				continue
 			}
 		}
 
+		if popt.RemoveRequested {
+			status, err := c.Requester.RequestStatus(ctx, m.ID)
+			if err != nil {
+				slog.Warn().Err(err).Msg("Error checking requests, keeping the film")
+			} else if status.IsRequested() {
+				slog.Debug().Str("status", status.String()).Msg("Film already requested")
+				continue
+			}
+		}
+
 		// Finally, if still keep...
 		ret = append(ret, f)
 	}
 	return ret, nil
 }
 
+// ListFilms returns all the films on the given lists
+func (c *Client) ListFilms(ctx context.Context, lists []*letterboxd.ListID) ([]*letterboxd.Film, error) {
+	if len(lists) == 0 {
+		return nil, errors.New("at least one list is required")
+	}
+	return c.letterboxdFilms(ctx, &letterboxd.FilmBatchOpts{List: lists})
+}
+
+// letterboxdFilms scrapes the films in the batch from Letterboxd, retrying
+// the whole batch if it fails
+func (c *Client) letterboxdFilms(ctx context.Context, batch *letterboxd.FilmBatchOpts) ([]*letterboxd.Film, error) {
+	var films []*letterboxd.Film
+	err := c.Resilience.Do(ctx, ServiceLetterboxd, func(ctx context.Context) error {
+		isoC := make(chan *letterboxd.Film)
+		done := make(chan error)
+		go c.LetterboxdClient.Film.StreamBatch(ctx, batch, isoC, done)
+		var err error
+		films, err = letterboxd.SlurpFilms(isoC, done)
+		return err
+	})
+	return films, err
+}
+
+// SupplementPlan returns the films from the collected lists that supplement would
+// request. That is anything unwatched that I can't already stream, and that
+// hasn't already been requested
+func (c *Client) SupplementPlan(ctx context.Context, me *PersonInfo, collect *MovieCollectOpts, globs []string) ([]*letterboxd.Film, error) {
+	// Catch a bad glob before fetching any lists
+	if _, err := CompileGlobs(globs); err != nil {
+		return nil, err
+	}
+	isoFilms, err := c.CollectFilms(ctx, me, collect)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msg("Pruning film list")
+	return c.PruneFilms(ctx, isoFilms, PruneOpts{
+		RemoveTitleGlobs:     globs,
+		RemoveWatched:        true,
+		RemoveMyStreaming:    true,
+		RemoveMyMediaServers: true,
+		RemoveRequested:      true,
+	})
+}
+
+// Names for the PruneOpts removals, as used in exclude lists
+const (
+	ExcludeWatched     = "watched"
+	ExcludeMyStreaming = "my-streaming"
+	ExcludeMyServers   = "my-servers"
+	ExcludeMyRadarr    = "radarr"
+	ExcludeRequested   = "requested"
+)
+
+// NewPruneOptsWithExcludes returns PruneOpts with a removal turned on for each
+// of the given exclude names, such as 'watched' or 'my-streaming'
+func NewPruneOptsWithExcludes(excludes []string) (*PruneOpts, error) {
+	opts := &PruneOpts{}
+	for _, e := range excludes {
+		switch strings.TrimSpace(e) {
+		case "":
+		case ExcludeWatched:
+			opts.RemoveWatched = true
+		case ExcludeMyStreaming:
+			opts.RemoveMyStreaming = true
+		case ExcludeMyServers:
+			opts.RemoveMyMediaServers = true
+		case ExcludeMyRadarr:
+			opts.RemoveMyRadarr = true
+		case ExcludeRequested:
+			opts.RemoveRequested = true
+		default:
+			return nil, fmt.Errorf("unknown exclude: %v", e)
+		}
+	}
+	return opts, nil
+}
+
 type PruneOpts struct {
-	RemoveTitleGlobs  []string
-	RemoveWatched     bool
-	RemoveMyStreaming bool
-	RemoveMyPlex      bool
-	RemoveMyRadarr    bool
+	RemoveTitleGlobs     []string
+	RemoveWatched        bool
+	RemoveMyStreaming    bool
+	RemoveMyPlex         bool
+	RemoveMyMediaServers bool
+	RemoveMyRadarr       bool
+	RemoveRequested      bool
 }
 
 func NewClient(config ClientConfig) (*Client, error) {
@@ -161,6 +315,7 @@ func NewClient(config ClientConfig) (*Client, error) {
 	c := &Client{
 		HTTPClient: config.HTTPClient,
 		UserAgent:  userAgent,
+		Resilience: NewResilience(config.RetryPolicies),
 	}
 	// Enable cache if configured
 	if config.Cache != nil {
@@ -172,6 +327,7 @@ func NewClient(config ClientConfig) (*Client, error) {
 		log.Warn().Err(err).Msg("Error initializing tmdb client")
 		return nil, err
 	}
+	tmdbC.SetClientConfig(*c.httpClient(ServiceTMDB))
 	// c.TMDBClient = tmdbC
 	c.TMDB = &TMDBServiceOp{
 		client:     c,
@@ -179,27 +335,84 @@ func NewClient(config ClientConfig) (*Client, error) {
 	}
 
 	// Plex Client
-	plexC, err := plex.New(config.PlexURL, config.PlexToken)
-	if err != nil {
-		log.Warn().Err(err).Msg("Error initializing plex client")
-		return nil, err
+	if config.PlexURL != "" {
+		plexC, err := plex.New(config.PlexURL, config.PlexToken)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error initializing plex client")
+			return nil, err
+		}
+		plexC.HTTPClient = *c.httpClient(ServicePlex)
+		// c.PlexClient = plexC
+		c.Plex = &PlexServiceOp{
+			client:     c,
+			plexClient: plexC,
+		}
+		c.MediaServers = append(c.MediaServers, c.Plex)
 	}
-	// c.PlexClient = plexC
-	c.Plex = &PlexServiceOp{
-		client:     c,
-		plexClient: plexC,
+
+	// Jellyfin and Emby
+	if config.JellyfinURL != "" {
+		c.MediaServers = append(c.MediaServers, &EmbyServiceOp{
+			client:  c,
+			kind:    MediaServerJellyfin,
+			baseURL: config.JellyfinURL,
+			apiKey:  config.JellyfinKey,
+		})
+	}
+	if config.EmbyURL != "" {
+		c.MediaServers = append(c.MediaServers, &EmbyServiceOp{
+			client:  c,
+			kind:    MediaServerEmby,
+			baseURL: config.EmbyURL,
+			apiKey:  config.EmbyKey,
+		})
 	}
 
 	c.LetterboxdClient = letterboxd.NewClient(config.LetterboxdConfig)
 
 	// Radarr Stuff
 	sc := starr.New(config.RadarrKey, config.RadarrURL, 0)
+	sc.Client = c.httpClient(ServiceRadarr)
 	// c.RadarrClient = radarr.New(sc)
 	c.Radarr = &RadarrServiceOp{
 		client:       c,
 		radarrClient: radarr.New(sc),
 	}
 
+	c.Overseerr = &OverseerrServiceOp{
+		client:  c,
+		baseURL: config.OverseerrURL,
+		apiKey:  config.OverseerrKey,
+	}
+
+	c.Requester, err = c.requesterWithBackend(config.RequestBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Notify, err = newNotifyService(c, config.Notifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.PosterProtocol != "" {
+		if err = ValidPosterProtocol(config.PosterProtocol); err != nil {
+			return nil, err
+		}
+	}
+	c.Posters = &PosterServiceOp{
+		client:   c,
+		baseURL:  tmdbImageBaseURL,
+		cacheDir: config.PosterCacheDir,
+	}
+
+	if config.StorePath != "" {
+		c.Store, err = NewBoltStore(config.StorePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	c.Config = &config
 	return c, nil
 }
@@ -211,10 +424,50 @@ func NewClientWithViper(v viper.Viper) (*Client, error) {
 	config.TMDBKey = v.GetString("tmdb_key")
 	config.PlexURL = v.GetString("plex_url")
 	config.PlexToken = v.GetString("plex_token")
+	config.PlexWatched = v.GetBool("plex-watched")
+	config.PlexWatchedUsers = v.GetStringSlice("plex-watched-users")
+	config.JellyfinURL = v.GetString("jellyfin_url")
+	config.JellyfinKey = v.GetString("jellyfin_key")
+	config.EmbyURL = v.GetString("emby_url")
+	config.EmbyKey = v.GetString("emby_key")
 	config.RadarrURL = v.GetString("radarr_url")
 	config.RadarrKey = v.GetString("radarr_key")
 	config.RadarrQuality = v.GetString("radarr_quality")
 	config.RadarrPath = v.GetString("radarr_path")
+	config.OverseerrURL = v.GetString("overseerr_url")
+	config.OverseerrKey = v.GetString("overseerr_key")
+	config.RequestBackend = v.GetString("request_backend")
+	if err := v.UnmarshalKey("notifiers", &config.Notifiers); err != nil {
+		return nil, err
+	}
+	config.StorePath = v.GetString("state_db")
+	if config.StorePath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			config.StorePath = filepath.Join(home, ".letswatch.db")
+		} else {
+			log.Warn().Err(err).Msg("No home directory, film history will not be kept")
+		}
+	}
+	config.BallotPath = v.GetString("ballot")
+	if config.BallotPath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			config.BallotPath = filepath.Join(home, ".letswatch-ballot.yaml")
+		}
+	}
+	config.JSONListHosts = v.GetStringSlice("json_list_hosts")
+	config.MatchThreshold = v.GetFloat64("match_threshold")
+	if err := v.UnmarshalKey("retry", &config.RetryPolicies); err != nil {
+		return nil, err
+	}
+	config.PosterProtocol = v.GetString("poster_protocol")
+	config.PosterCacheDir = v.GetString("poster_cache_dir")
+	if config.PosterCacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			config.PosterCacheDir = filepath.Join(dir, "letswatch", "posters")
+		} else {
+			log.Warn().Err(err).Msg("No cache directory, posters will not be kept")
+		}
+	}
 
 	if v.GetBool("use_cache") {
 		rdb := redis.NewClient(&redis.Options{
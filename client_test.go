@@ -2,6 +2,7 @@ package letswatch
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/drewstinnett/go-letterboxd"
@@ -43,7 +44,26 @@ func TestNewClientWithViper(t *testing.T) {
 	v.Set("plex_url", "https://plex.example.com")
 	v.Set("plex_token", "token")
 	v.Set("redis-host", "http://localhost:8888")
+	v.Set("state_db", filepath.Join(t.TempDir(), "letswatch.db"))
+	v.Set("poster_cache_dir", t.TempDir())
+	v.Set("poster_protocol", "kitty")
 	got, err := NewClientWithViper(*v)
 	require.NoError(t, err)
 	require.NotNil(t, got)
+	require.NotNil(t, got.Store)
+	require.NotNil(t, got.Posters)
+	require.Equal(t, PosterKitty, got.posterProtocol())
+
+	v.Set("poster_protocol", "ascii-art")
+	_, err = NewClientWithViper(*v)
+	require.EqualError(t, err, "unknown poster protocol: ascii-art")
+}
+
+func TestNewPruneOptsWithExcludes(t *testing.T) {
+	got, err := NewPruneOptsWithExcludes([]string{"watched", " my-streaming", ""})
+	require.NoError(t, err)
+	require.Equal(t, &PruneOpts{RemoveWatched: true, RemoveMyStreaming: true}, got)
+
+	_, err = NewPruneOptsWithExcludes([]string{"unwatched"})
+	require.EqualError(t, err, "unknown exclude: unwatched")
 }
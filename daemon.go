@@ -0,0 +1,452 @@
+package letswatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of daemon job
+const (
+	JobSupplement   = "supplement"
+	JobRefreshCache = "refresh-cache"
+	JobRecommend    = "recommend"
+)
+
+// Job is a task the daemon runs on a schedule, as defined in the config
+type Job struct {
+	Name       string        `mapstructure:"name" yaml:"name"`
+	Kind       string        `mapstructure:"kind" yaml:"kind"`
+	Every      time.Duration `mapstructure:"every" yaml:"every"`
+	Lists      []string      `mapstructure:"lists" yaml:"lists,omitempty"`
+	JSONLists  []string      `mapstructure:"json_lists" yaml:"json_lists,omitempty"`
+	MatchGlobs []string      `mapstructure:"match_globs" yaml:"match_globs,omitempty"`
+	DryRun     bool          `mapstructure:"dry_run" yaml:"dry_run,omitempty"`
+	Watchlist  bool          `mapstructure:"watchlist" yaml:"watchlist,omitempty"`
+	// Filter takes the same options as the recommend flags, such as
+	// 'only-my-streaming: true'
+	Filter map[string]string `mapstructure:"filter" yaml:"filter,omitempty"`
+}
+
+// CollectOpts returns the collect options for the lists in the job
+func (j *Job) CollectOpts() (*MovieCollectOpts, error) {
+	lists, err := parseListArgs(j.Lists)
+	if err != nil {
+		return nil, err
+	}
+	return &MovieCollectOpts{
+		Lists:     lists,
+		JSONLists: j.JSONLists,
+		Watchlist: j.Watchlist,
+	}, nil
+}
+
+// FilterOpts returns the filter options for the job
+func (j *Job) FilterOpts() (*MovieFilterOpts, error) {
+	q := url.Values{}
+	for k, v := range j.Filter {
+		q.Set(k, v)
+	}
+	return NewMovieFilterOptsWithValues(q)
+}
+
+// JobRunner does the work for a kind of job, returning a short summary of
+// what it did. The runner is given a copy of the job state, and any Seen IDs
+// it sets are kept if the run succeeds
+type JobRunner func(context.Context, *Job, *JobState) (string, error)
+
+// JobState is what we remember about a job between runs
+type JobState struct {
+	LastRun             time.Time `json:"last_run,omitempty" yaml:"last_run,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty" yaml:"last_success,omitempty"`
+	LastResult          string    `json:"last_result,omitempty" yaml:"last_result,omitempty"`
+	LastError           string    `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty" yaml:"consecutive_failures,omitempty"`
+	NextRun             time.Time `json:"next_run,omitempty" yaml:"next_run,omitempty"`
+	// Seen are the IMDB IDs a recommend job has already notified about
+	Seen []string `json:"seen,omitempty" yaml:"-"`
+}
+
+// DaemonState is persisted to disk after every job run
+type DaemonState struct {
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// JobStatus is a job along with its state, for display
+type JobStatus struct {
+	Job   *Job      `yaml:"job"`
+	State *JobState `yaml:"state"`
+}
+
+// Daemon runs jobs on a schedule, backing off when they fail
+type Daemon struct {
+	Jobs      []*Job
+	StatePath string
+	Runners   map[string]JobRunner
+	// MinBackoff is the first retry delay after a failure. It doubles with
+	// each consecutive failure, up to the job's interval
+	MinBackoff time.Duration
+	// Notify, if set, is sent a run-failed event when a job fails
+	Notify NotifyService
+
+	mu    sync.Mutex
+	state *DaemonState
+	now   func() time.Time
+}
+
+// NewDaemon validates the jobs and loads any previous state from statePath
+func NewDaemon(jobs []*Job, statePath string, runners map[string]JobRunner) (*Daemon, error) {
+	seen := map[string]bool{}
+	for _, j := range jobs {
+		if j.Name == "" {
+			return nil, errors.New("every job needs a name")
+		}
+		if seen[j.Name] {
+			return nil, fmt.Errorf("duplicate job name: %v", j.Name)
+		}
+		seen[j.Name] = true
+		if j.Every <= 0 {
+			return nil, fmt.Errorf("job %v needs an 'every' interval", j.Name)
+		}
+		if _, ok := runners[j.Kind]; !ok {
+			return nil, fmt.Errorf("job %v has unknown kind: %v", j.Name, j.Kind)
+		}
+	}
+	d := &Daemon{
+		Jobs:       jobs,
+		StatePath:  statePath,
+		Runners:    runners,
+		MinBackoff: time.Minute,
+		now:        time.Now,
+	}
+	state, err := LoadDaemonState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	d.state = state
+	return d, nil
+}
+
+// LoadDaemonState reads the state file, returning an empty state if it does
+// not exist yet
+func LoadDaemonState(path string) (*DaemonState, error) {
+	state := &DaemonState{Jobs: map[string]*JobState{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Jobs == nil {
+		state.Jobs = map[string]*JobState{}
+	}
+	return state, nil
+}
+
+func (d *Daemon) saveState() error {
+	data, err := json.MarshalIndent(d.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write and rename, so a crash never leaves a half written file
+	tmp := d.StatePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.StatePath)
+}
+
+func (d *Daemon) jobState(name string) *JobState {
+	st, ok := d.state.Jobs[name]
+	if !ok {
+		st = &JobState{}
+		d.state.Jobs[name] = st
+	}
+	return st
+}
+
+// backoff returns how long to wait after the given number of consecutive
+// failures
+func (d *Daemon) backoff(j *Job, failures int) time.Duration {
+	wait := d.MinBackoff
+	for i := 1; i < failures && wait < j.Every; i++ {
+		wait *= 2
+	}
+	if wait > j.Every {
+		wait = j.Every
+	}
+	return wait
+}
+
+// runJob runs a single job and records the result
+func (d *Daemon) runJob(ctx context.Context, j *Job) {
+	slog := log.With().Str("job", j.Name).Str("kind", j.Kind).Logger()
+	slog.Info().Msg("Running job")
+	d.mu.Lock()
+	run := *d.jobState(j.Name)
+	d.mu.Unlock()
+	result, err := d.Runners[j.Kind](ctx, j, &run)
+
+	d.mu.Lock()
+	st := d.jobState(j.Name)
+	st.LastRun = d.now()
+	if err != nil {
+		st.ConsecutiveFailures++
+		st.LastError = err.Error()
+		st.NextRun = st.LastRun.Add(d.backoff(j, st.ConsecutiveFailures))
+		slog.Warn().Err(err).Int("failures", st.ConsecutiveFailures).Time("next-run", st.NextRun).Msg("Job failed")
+	} else {
+		st.ConsecutiveFailures = 0
+		st.LastError = ""
+		st.LastSuccess = st.LastRun
+		st.LastResult = result
+		st.NextRun = st.LastRun.Add(j.Every)
+		st.Seen = run.Seen
+		slog.Info().Str("result", result).Time("next-run", st.NextRun).Msg("Job finished")
+	}
+	if serr := d.saveState(); serr != nil {
+		slog.Warn().Err(serr).Msg("Error saving daemon state")
+	}
+	d.mu.Unlock()
+
+	if err != nil && d.Notify != nil {
+		// Already logged by the notify service
+		_ = d.Notify.Send(ctx, NewRunFailedNotification(j.Name, err))
+	}
+}
+
+// RunDue runs every job that is due, and returns when the next one will be
+func (d *Daemon) RunDue(ctx context.Context) time.Time {
+	for _, j := range d.Jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		d.mu.Lock()
+		next := d.jobState(j.Name).NextRun
+		d.mu.Unlock()
+		if !next.After(d.now()) {
+			d.runJob(ctx, j)
+		}
+	}
+	return d.nextWake()
+}
+
+func (d *Daemon) nextWake() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var ret time.Time
+	for _, j := range d.Jobs {
+		next := d.jobState(j.Name).NextRun
+		if ret.IsZero() || next.Before(ret) {
+			ret = next
+		}
+	}
+	return ret
+}
+
+// Run holds the lock and runs jobs until the context is cancelled
+func (d *Daemon) Run(ctx context.Context) error {
+	if len(d.Jobs) == 0 {
+		return errors.New("no daemon jobs are configured")
+	}
+	release, err := AcquireLock(d.StatePath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for {
+		next := d.RunDue(ctx)
+		wait := next.Sub(d.now())
+		if wait < 0 {
+			wait = 0
+		}
+		log.Debug().Str("wait", fmt.Sprint(wait)).Msg("Waiting for next job")
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Status returns every configured job with its state, soonest first
+func (d *Daemon) Status() []*JobStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	ret := []*JobStatus{}
+	for _, j := range d.Jobs {
+		st := *d.jobState(j.Name)
+		ret = append(ret, &JobStatus{Job: j, State: &st})
+	}
+	sort.SliceStable(ret, func(i, k int) bool {
+		return ret[i].State.NextRun.Before(ret[k].State.NextRun)
+	})
+	return ret
+}
+
+// AcquireLock creates a lock file holding our PID, so only one daemon runs at
+// a time. A lock left behind by a process that is no longer running is taken
+// over
+func AcquireLock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		data, rerr := ioutil.ReadFile(path)
+		if rerr != nil {
+			return nil, rerr
+		}
+		pid, perr := strconv.Atoi(strings.TrimSpace(string(data)))
+		if perr == nil && processRunning(pid) {
+			return nil, fmt.Errorf("another daemon (pid %v) holds the lock at %v", pid, path)
+		}
+		log.Warn().Str("lock", path).Msg("Removing stale lock")
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("could not acquire lock at %v", path)
+}
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// DaemonRunners returns the runners for each kind of job, backed by this client
+func (c *Client) DaemonRunners(me *PersonInfo) map[string]JobRunner {
+	return map[string]JobRunner{
+		JobSupplement: func(ctx context.Context, j *Job, _ *JobState) (string, error) {
+			collect, err := j.CollectOpts()
+			if err != nil {
+				return "", err
+			}
+			films, err := c.SupplementPlan(ctx, me, collect, j.MatchGlobs)
+			if err != nil {
+				return "", err
+			}
+			if j.DryRun {
+				return fmt.Sprintf("would request %v films", len(films)), nil
+			}
+			requested, err := c.RequestFilms(ctx, films)
+			if len(requested) > 0 {
+				_ = c.Notify.Send(ctx, NewFilmsNotification(EventFilmsAdded, fmt.Sprintf("%v: requested %v films", j.Name, len(requested)), requested))
+			}
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("requested %v films", len(requested)), nil
+		},
+		// Notify about recommendations that weren't there on the last run
+		JobRecommend: func(ctx context.Context, j *Job, st *JobState) (string, error) {
+			collect, err := j.CollectOpts()
+			if err != nil {
+				return "", err
+			}
+			filter, err := j.FilterOpts()
+			if err != nil {
+				return "", err
+			}
+			if err = filter.ValidateWithPerson(me); err != nil {
+				return "", err
+			}
+			movies, err := c.Recommend(ctx, me, filter, collect)
+			if err != nil {
+				return "", err
+			}
+			newMovies := []*Movie{}
+			seen := []string{}
+			for _, m := range movies {
+				seen = append(seen, m.IMDBID)
+				if !ContainsString(st.Seen, m.IMDBID) {
+					newMovies = append(newMovies, m)
+				}
+			}
+			// The first run only learns what is already recommended, rather
+			// than announcing all of it
+			if st.LastSuccess.IsZero() {
+				st.Seen = seen
+				return fmt.Sprintf("%v recommendations, first run", len(movies)), nil
+			}
+			st.Seen = seen
+			if len(newMovies) > 0 {
+				n := &Notification{
+					Event: EventNewRecommendations,
+					Title: fmt.Sprintf("%v: %v new recommendations", j.Name, len(newMovies)),
+				}
+				for _, m := range newMovies {
+					n.Films = append(n.Films, filmDisplayName(m.Title, m.ReleaseYear))
+				}
+				_ = c.Notify.Send(ctx, n)
+			}
+			return fmt.Sprintf("%v recommendations, %v new", len(movies), len(newMovies)), nil
+		},
+		// Fetch every film on the lists again, so the cache is fresh and warm
+		// for later runs
+		JobRefreshCache: func(ctx context.Context, j *Job, _ *JobState) (string, error) {
+			collect, err := j.CollectOpts()
+			if err != nil {
+				return "", err
+			}
+			films, err := c.CollectFilms(ctx, me, collect)
+			if err != nil {
+				return "", err
+			}
+			var refreshed int
+			for _, f := range films {
+				if err := c.refreshFilm(ctx, FilmRefWithLetterboxd(f)); err != nil {
+					log.Warn().Err(err).Str("title", f.Title).Msg("Error refreshing film")
+					continue
+				}
+				refreshed++
+			}
+			return fmt.Sprintf("refreshed %v of %v films", refreshed, len(films)), nil
+		},
+	}
+}
+
+// refreshFilm fetches a film's details again, replacing what is cached. Films
+// without a TMDB ID are resolved first to find it
+func (c *Client) refreshFilm(ctx context.Context, ref FilmRef) error {
+	id, err := strconv.Atoi(ref.TMDBID)
+	if err != nil {
+		m, err := c.ResolveFilm(ctx, ref)
+		if err != nil {
+			return err
+		}
+		id = int(m.ID)
+	}
+	_, err = c.TMDB.Refresh(ctx, id)
+	return err
+}
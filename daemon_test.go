@@ -0,0 +1,148 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDaemonValidation(t *testing.T) {
+	runners := map[string]JobRunner{
+		"noop": func(context.Context, *Job, *JobState) (string, error) { return "", nil },
+	}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	tests := map[string]struct {
+		jobs    []*Job
+		wantErr string
+	}{
+		"good":     {jobs: []*Job{{Name: "a", Kind: "noop", Every: time.Hour}}},
+		"no-name":  {jobs: []*Job{{Kind: "noop", Every: time.Hour}}, wantErr: "every job needs a name"},
+		"dup":      {jobs: []*Job{{Name: "a", Kind: "noop", Every: time.Hour}, {Name: "a", Kind: "noop", Every: time.Hour}}, wantErr: "duplicate job name: a"},
+		"no-every": {jobs: []*Job{{Name: "a", Kind: "noop"}}, wantErr: "job a needs an 'every' interval"},
+		"bad-kind": {jobs: []*Job{{Name: "a", Kind: "nope", Every: time.Hour}}, wantErr: "job a has unknown kind: nope"},
+	}
+	for k, tt := range tests {
+		_, err := NewDaemon(tt.jobs, statePath, runners)
+		if tt.wantErr != "" {
+			require.EqualError(t, err, tt.wantErr, k)
+		} else {
+			require.NoError(t, err, k)
+		}
+	}
+}
+
+func TestDaemonRunDue(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	var calls int
+	fail := true
+	runners := map[string]JobRunner{
+		"flaky": func(_ context.Context, _ *Job, st *JobState) (string, error) {
+			calls++
+			st.Seen = []string{"tt4016934"}
+			if fail {
+				return "", errors.New("boom")
+			}
+			return "did it", nil
+		},
+	}
+	jobs := []*Job{{Name: "flaky", Kind: "flaky", Every: time.Hour}}
+	d, err := NewDaemon(jobs, statePath, runners)
+	require.NoError(t, err)
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	// First run fails, and backs off by the minimum
+	next := d.RunDue(context.Background())
+	require.Equal(t, 1, calls)
+	require.Equal(t, now.Add(time.Minute), next)
+
+	// Not due yet
+	d.RunDue(context.Background())
+	require.Equal(t, 1, calls)
+
+	// Second failure doubles the backoff
+	now = now.Add(time.Minute)
+	next = d.RunDue(context.Background())
+	require.Equal(t, now.Add(2*time.Minute), next)
+
+	// Success resets the backoff and waits the full interval
+	fail = false
+	now = next
+	next = d.RunDue(context.Background())
+	require.Equal(t, now.Add(time.Hour), next)
+
+	// State survives a restart
+	state, err := LoadDaemonState(statePath)
+	require.NoError(t, err)
+	require.Equal(t, "did it", state.Jobs["flaky"].LastResult)
+	require.Equal(t, 0, state.Jobs["flaky"].ConsecutiveFailures)
+	require.Empty(t, state.Jobs["flaky"].LastError)
+	require.Equal(t, []string{"tt4016934"}, state.Jobs["flaky"].Seen)
+}
+
+func TestDaemonBackoffCap(t *testing.T) {
+	d := &Daemon{MinBackoff: time.Minute}
+	j := &Job{Every: 10 * time.Minute}
+	require.Equal(t, time.Minute, d.backoff(j, 1))
+	require.Equal(t, 8*time.Minute, d.backoff(j, 4))
+	require.Equal(t, 10*time.Minute, d.backoff(j, 5))
+	require.Equal(t, 10*time.Minute, d.backoff(j, 50))
+}
+
+func TestAcquireLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "daemon.lock")
+	release, err := AcquireLock(lockPath)
+	require.NoError(t, err)
+
+	// We are still running, so a second lock fails
+	_, err = AcquireLock(lockPath)
+	require.Error(t, err)
+	release()
+
+	// Stale locks are taken over
+	require.NoError(t, os.WriteFile(lockPath, []byte("999999999\n"), 0o600))
+	release, err = AcquireLock(lockPath)
+	require.NoError(t, err)
+	release()
+	_, err = os.Stat(lockPath)
+	require.True(t, os.IsNotExist(err))
+}
+
+// sentNotifications keeps what would have been sent
+type sentNotifications []*Notification
+
+func (s *sentNotifications) Send(_ context.Context, n *Notification) error {
+	*s = append(*s, n)
+	return nil
+}
+
+func TestDaemonRecommendFirstRun(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c, list := newRecommendTestClient(t)
+	sent := &sentNotifications{}
+	c.Notify = sent
+	run := c.DaemonRunners(&PersonInfo{})[JobRecommend]
+	j := &Job{Name: "new", Kind: JobRecommend, JSONLists: []string{list}, Filter: map[string]string{"include-watched": "true", "earliest": "2000"}}
+
+	// The first run learns what is there without announcing it
+	st := &JobState{}
+	got, err := run(context.Background(), j, st)
+	require.NoError(t, err)
+	require.Equal(t, "2 recommendations, first run", got)
+	require.Empty(t, *sent)
+	require.Equal(t, []string{"tt4016934", "tt4016934"}, st.Seen)
+
+	// Later runs only announce films that weren't there before
+	st = &JobState{LastSuccess: time.Now(), Seen: []string{"tt0000001"}}
+	got, err = run(context.Background(), j, st)
+	require.NoError(t, err)
+	require.Equal(t, "2 recommendations, 2 new", got)
+	require.Len(t, *sent, 1)
+}
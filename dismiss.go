@@ -0,0 +1,103 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var errNoStore = errors.New("no state store is configured, set 'state_db' in the config")
+
+// ParseSnooze parses how long to snooze a film for. On top of the usual Go
+// durations, whole days and weeks may be given, like '90d' or '2w'. It must be
+// more than zero, a zero snooze would dismiss the film forever
+func ParseSnooze(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	} {
+		if strings.HasSuffix(s, suffix) {
+			i, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil || i <= 0 {
+				return 0, fmt.Errorf("invalid duration: %v", s)
+			}
+			return time.Duration(i) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration: %v", s)
+	}
+	return d, nil
+}
+
+// Dismiss hides a film from recommendations. A zero duration hides it forever,
+// otherwise it is snoozed for that long
+func (c *Client) Dismiss(ctx context.Context, imdbID string, d time.Duration) (*FilmHistory, error) {
+	if c.Store == nil {
+		return nil, errNoStore
+	}
+	// The title and year just make the dismissed list easier to read
+	var title string
+	var year int
+	if m, err := c.TMDB.GetWithIMDBID(ctx, imdbID); err != nil {
+		log.Warn().Err(err).Str("imdb", imdbID).Msg("Could not look up film, dismissing anyway")
+	} else {
+		movie := NewMovieWithTMDB(m)
+		title, year = movie.Title, movie.ReleaseYear
+	}
+	var until time.Time
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	if err := c.Store.Dismiss(imdbID, title, year, until); err != nil {
+		return nil, err
+	}
+	return c.Store.Get(imdbID)
+}
+
+// Dismissed returns the films that are currently dismissed or snoozed
+func (c *Client) Dismissed() ([]*FilmHistory, error) {
+	if c.Store == nil {
+		return nil, errNoStore
+	}
+	all, err := c.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	ret := []*FilmHistory{}
+	for _, h := range all {
+		if h.IsDismissed(now) {
+			ret = append(ret, h)
+		}
+	}
+	return ret, nil
+}
+
+// Undismiss brings back a dismissed or snoozed film
+func (c *Client) Undismiss(imdbID string) error {
+	if c.Store == nil {
+		return errNoStore
+	}
+	return c.Store.Undismiss(imdbID)
+}
+
+// IsDismissed returns true if the film is dismissed or snoozed. Store errors
+// are logged and treated as not dismissed
+func (c *Client) IsDismissed(imdbID string) bool {
+	if c.Store == nil || imdbID == "" {
+		return false
+	}
+	h, err := c.Store.Get(imdbID)
+	if err != nil {
+		log.Warn().Err(err).Str("imdb", imdbID).Msg("Error checking if film is dismissed")
+		return false
+	}
+	return h.IsDismissed(time.Now())
+}
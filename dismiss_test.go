@@ -0,0 +1,94 @@
+package letswatch
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSnooze(t *testing.T) {
+	tests := map[string]struct {
+		want    time.Duration
+		wantErr bool
+	}{
+		"90d":  {want: 90 * 24 * time.Hour},
+		"2w":   {want: 14 * 24 * time.Hour},
+		"36h":  {want: 36 * time.Hour},
+		"d":    {wantErr: true},
+		"-3d":  {wantErr: true},
+		"0d":   {wantErr: true},
+		"0s":   {wantErr: true},
+		"soon": {wantErr: true},
+	}
+	for k, tt := range tests {
+		got, err := ParseSnooze(k)
+		if tt.wantErr {
+			require.Error(t, err, k)
+		} else {
+			require.NoError(t, err, k)
+			require.Equal(t, tt.want, got, k)
+		}
+	}
+}
+
+func TestStoreDismiss(t *testing.T) {
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// Forever
+	require.NoError(t, s.Dismiss("tt4016934", "The Handmaiden", 2016, time.Time{}))
+	h, err := s.Get("tt4016934")
+	require.NoError(t, err)
+	require.True(t, h.IsDismissed(now.Add(10*365*24*time.Hour)))
+	require.True(t, h.Has(HistoryNotInterested))
+
+	// Snoozed
+	require.NoError(t, s.Dismiss("tt0235198", "Audition", 1999, now.Add(time.Hour)))
+	h, err = s.Get("tt0235198")
+	require.NoError(t, err)
+	require.True(t, h.IsDismissed(now))
+	require.False(t, h.IsDismissed(now.Add(2*time.Hour)))
+	require.False(t, h.Has(HistoryNotInterested))
+
+	// Undo
+	require.NoError(t, s.Undismiss("tt4016934"))
+	h, err = s.Get("tt4016934")
+	require.NoError(t, err)
+	require.False(t, h.IsDismissed(now))
+	require.False(t, h.Has(HistoryNotInterested))
+	require.EqualError(t, s.Undismiss("tt4016934"), "film is not dismissed: tt4016934")
+
+	// Undoing something we've never heard of doesn't leave a record behind
+	require.Error(t, s.Undismiss("tt0000001"))
+	h, err = s.Get("tt0000001")
+	require.NoError(t, err)
+	require.Nil(t, h)
+}
+
+func TestClientHistory(t *testing.T) {
+	c := &Client{}
+	_, err := c.History()
+	require.Equal(t, errNoStore, err)
+	require.Equal(t, errNoStore, c.Undismiss("tt4016934"))
+
+	c.Store, err = NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	require.NoError(t, c.Store.Dismiss("tt4016934", "The Handmaiden", 2016, time.Time{}))
+	require.NoError(t, c.Store.Record("tt0235198", "Audition", 1999, HistoryPicked))
+
+	all, err := c.History()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	// Films we know nothing about are left out
+	some, err := c.History("tt0235198", "tt0000001")
+	require.NoError(t, err)
+	require.Len(t, some, 1)
+	require.Equal(t, "Audition", some[0].Title)
+
+	require.NoError(t, c.Undismiss("tt4016934"))
+	require.False(t, c.IsDismissed("tt4016934"))
+}
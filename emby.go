@@ -0,0 +1,79 @@
+package letswatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EmbyServiceOp is a MediaServer for Emby and Jellyfin. Jellyfin forked from
+// Emby, and both still share the Items search API
+type EmbyServiceOp struct {
+	client  *Client
+	kind    string
+	baseURL string
+	apiKey  string
+}
+
+type embyItems struct {
+	Items []struct {
+		Name           string `json:"Name"`
+		OriginalTitle  string `json:"OriginalTitle"`
+		ProductionYear int    `json:"ProductionYear"`
+	} `json:"Items"`
+}
+
+func (e *EmbyServiceOp) Name() string {
+	return e.kind
+}
+
+func (e *EmbyServiceOp) IsAvailable(ctx context.Context, title string, year int) (bool, error) {
+	if e.baseURL == "" {
+		return false, errors.New("ErrNoMediaServerURL")
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	q := url.Values{}
+	q.Set("SearchTerm", title)
+	q.Set("IncludeItemTypes", "Movie")
+	q.Set("Recursive", "true")
+	u := fmt.Sprintf("%v/Items?%v", strings.TrimSuffix(e.baseURL, "/"), q.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Emby-Token", e.apiKey)
+	req.Header.Set("User-Agent", e.client.UserAgent)
+	res, err := e.client.httpClient(e.kind).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return false, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%v returned %v: %s", e.kind, res.StatusCode, data)
+	}
+	var items embyItems
+	if err := json.Unmarshal(data, &items); err != nil {
+		return false, err
+	}
+
+	padding := 2
+	earliest := year - padding
+	latest := year + padding
+	for _, i := range items.Items {
+		if (i.Name == title || i.OriginalTitle == title) && inBetween(i.ProductionYear, earliest, latest) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,38 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailableOnJellyfin(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	items, err := ioutil.ReadFile("testdata/jellyfin_items.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "=~^https://jellyfin.example.com/Items",
+		httpmock.NewStringResponder(200, string(items)))
+	httpmock.RegisterResponder("GET", "=~^https://emby.example.com/emby/Items",
+		httpmock.NewStringResponder(500, "oops"))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:     "foo",
+		JellyfinURL: "https://jellyfin.example.com",
+		EmbyURL:     "https://emby.example.com/emby/",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, c.Plex)
+	require.Len(t, c.MediaServers, 2)
+
+	require.Equal(t, []string{MediaServerJellyfin}, c.AvailableOn(context.Background(), "The Handmaiden", 2016))
+	require.Equal(t, []string{}, c.AvailableOn(context.Background(), "The Handmaiden", 1990))
+}
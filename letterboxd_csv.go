@@ -0,0 +1,111 @@
+package letswatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LetterboxdCSVOpts are the optional columns of a Letterboxd import
+type LetterboxdCSVOpts struct {
+	// Rating adds the TMDB rating, converted to the Letterboxd 0.5-5 scale
+	Rating bool
+	// Review adds a short note about where the film is available
+	Review bool
+}
+
+// LetterboxdCSVWriter writes movies in the CSV format that Letterboxd accepts
+// for list and watchlist imports
+type LetterboxdCSVWriter struct {
+	w           *csv.Writer
+	opts        LetterboxdCSVOpts
+	wroteHeader bool
+}
+
+func NewLetterboxdCSVWriter(w io.Writer, opts LetterboxdCSVOpts) *LetterboxdCSVWriter {
+	return &LetterboxdCSVWriter{
+		w:    csv.NewWriter(w),
+		opts: opts,
+	}
+}
+
+func (l *LetterboxdCSVWriter) header() []string {
+	ret := []string{"imdbID", "tmdbID", "Title", "Year"}
+	if l.opts.Rating {
+		ret = append(ret, "Rating")
+	}
+	if l.opts.Review {
+		ret = append(ret, "Review")
+	}
+	return ret
+}
+
+// Write writes a single movie, and the header if this is the first one. Rows
+// are flushed as they are written so output can be streamed
+func (l *LetterboxdCSVWriter) Write(m *Movie) error {
+	if !l.wroteHeader {
+		if err := l.w.Write(l.header()); err != nil {
+			return err
+		}
+		l.wroteHeader = true
+	}
+	var year string
+	if m.ReleaseYear > 0 {
+		year = strconv.Itoa(m.ReleaseYear)
+	}
+	row := []string{m.IMDBID, m.TMDBID, m.Title, year}
+	if l.opts.Rating {
+		row = append(row, letterboxdRating(m.Rating))
+	}
+	if l.opts.Review {
+		row = append(row, m.AvailabilitySummary())
+	}
+	if err := l.w.Write(row); err != nil {
+		return err
+	}
+	l.w.Flush()
+	return l.w.Error()
+}
+
+// Flush writes the header if nothing else has been written, so an empty
+// result is still a valid import file
+func (l *LetterboxdCSVWriter) Flush() error {
+	if !l.wroteHeader {
+		if err := l.w.Write(l.header()); err != nil {
+			return err
+		}
+		l.wroteHeader = true
+	}
+	l.w.Flush()
+	return l.w.Error()
+}
+
+// letterboxdRating converts a 0-10 TMDB rating to the nearest half star
+func letterboxdRating(r float64) string {
+	if r <= 0 {
+		return ""
+	}
+	stars := math.Round(r) / 2
+	if stars < 0.5 {
+		stars = 0.5
+	}
+	return strconv.FormatFloat(stars, 'f', -1, 64)
+}
+
+// AvailabilitySummary is a short human readable note on where a film can be
+// watched
+func (m *Movie) AvailabilitySummary() string {
+	parts := []string{}
+	if len(m.StreamingOnMy) > 0 {
+		parts = append(parts, fmt.Sprintf("Streaming on %v", strings.Join(m.StreamingOnMy, ", ")))
+	} else if len(m.StreamingOn) > 0 {
+		parts = append(parts, fmt.Sprintf("Streaming on %v", strings.Join(m.StreamingOn, ", ")))
+	}
+	if len(m.AvailableOn) > 0 {
+		parts = append(parts, fmt.Sprintf("In my library on %v", strings.Join(m.AvailableOn, ", ")))
+	}
+	return strings.Join(parts, ". ")
+}
@@ -0,0 +1,45 @@
+package letswatch
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLetterboxdCSVWriter(t *testing.T) {
+	tests := map[string]struct {
+		opts LetterboxdCSVOpts
+		want string
+	}{
+		"minimal": {
+			want: "imdbID,tmdbID,Title,Year\ntt4016934,290098,The Handmaiden,2016\n",
+		},
+		"all-columns": {
+			opts: LetterboxdCSVOpts{Rating: true, Review: true},
+			want: "imdbID,tmdbID,Title,Year,Rating,Review\ntt4016934,290098,The Handmaiden,2016,4,\"Streaming on Shudder, Hulu. In my library on plex\"\n",
+		},
+	}
+	for k, tt := range tests {
+		var b bytes.Buffer
+		w := NewLetterboxdCSVWriter(&b, tt.opts)
+		require.NoError(t, w.Write(&Movie{
+			Title:         "The Handmaiden",
+			ReleaseYear:   2016,
+			IMDBID:        "tt4016934",
+			TMDBID:        "290098",
+			Rating:        8.2,
+			StreamingOn:   []string{"Shudder", "Hulu", "Kanopy"},
+			StreamingOnMy: []string{"Shudder", "Hulu"},
+			AvailableOn:   []string{"plex"},
+		}), k)
+		require.NoError(t, w.Flush(), k)
+		require.Equal(t, tt.want, b.String(), k)
+	}
+}
+
+func TestLetterboxdCSVWriterEmpty(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, NewLetterboxdCSVWriter(&b, LetterboxdCSVOpts{}).Flush())
+	require.Equal(t, "imdbID,tmdbID,Title,Year\n", b.String())
+}
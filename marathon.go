@@ -0,0 +1,270 @@
+package letswatch
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Constraints on the films in a marathon
+const (
+	MarathonAny             = ""
+	MarathonSameDirector    = "same-director"
+	MarathonSameTheme       = "same-theme"
+	MarathonDifferentGenres = "different-genres"
+)
+
+// clockLayouts are the ways --start and --ends-by can be written
+var clockLayouts = []string{"15:04", "3:04pm", "3pm", "1504"}
+
+// parseClock returns the next time on or after now that the clock reads s,
+// like "23:00" or "11pm"
+func parseClock(s string, now time.Time) (time.Time, error) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	for _, layout := range clockLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		ret := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if ret.Before(now.Truncate(time.Minute)) {
+			ret = ret.AddDate(0, 0, 1)
+		}
+		return ret, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time of day: %v, use something like 23:00 or 11pm", s)
+}
+
+// StartTime is when something starting at the time of day start begins.
+// It defaults to now
+func StartTime(start string, now time.Time) (time.Time, error) {
+	if start == "" {
+		return now.Truncate(time.Minute), nil
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return parseClock(start, midnight)
+}
+
+// RuntimeUntil is how long there is between start and endsBy, both times of
+// day. start defaults to now. An endsBy earlier than start is the next day
+func RuntimeUntil(start, endsBy string, now time.Time) (time.Duration, error) {
+	from, err := StartTime(start, now)
+	if err != nil {
+		return 0, err
+	}
+	until, err := parseClock(endsBy, from)
+	if err != nil {
+		return 0, err
+	}
+	if !until.After(from) {
+		return 0, fmt.Errorf("nothing fits between %v and %v", from.Format("15:04"), until.Format("15:04"))
+	}
+	return until.Sub(from), nil
+}
+
+// applyEndsBy lowers MaxRuntime to whatever finishes by endsBy, if it is set
+func (m *MovieFilterOpts) applyEndsBy(start, endsBy string, now time.Time) error {
+	if endsBy == "" {
+		return nil
+	}
+	allowed, err := RuntimeUntil(start, endsBy, now)
+	if err != nil {
+		return err
+	}
+	if m.MaxRuntime == 0 || allowed < m.MaxRuntime {
+		m.MaxRuntime = allowed
+	}
+	return nil
+}
+
+// DefaultMarathonPlans is how many plans PlanMarathons returns when Plans
+// isn't set
+const DefaultMarathonPlans = 3
+
+// MarathonOpts are what PlanMarathons packs films in to
+type MarathonOpts struct {
+	Budget time.Duration
+	Count  int
+	// Break is the time between films
+	Break      time.Duration
+	Constraint string
+	// Plans is how many to return, the best first. It defaults to
+	// DefaultMarathonPlans
+	Plans int
+}
+
+// Marathon is a run of films that fits the budget
+type Marathon struct {
+	Films []*Movie
+	// Runtime is the films alone, Total includes the breaks
+	Runtime time.Duration
+	Total   time.Duration
+	Spare   time.Duration
+	// Theme is what the films have in common, for the same-* constraints
+	Theme string
+	// avg is the average film Score, and found is the order plans were found
+	// in, which breaks any other tie
+	avg   float64
+	found int
+}
+
+// better is true if m is a better plan than o, by score then the least spare
+// time
+func (m *Marathon) better(o *Marathon) bool {
+	if m.avg != o.avg {
+		return m.avg > o.avg
+	}
+	if m.Spare != o.Spare {
+		return m.Spare < o.Spare
+	}
+	return m.found < o.found
+}
+
+// marathonHeap is the best plans found so far, with the worst on top so it's
+// the one that goes when a better plan turns up
+type marathonHeap []*Marathon
+
+func (h marathonHeap) Len() int            { return len(h) }
+func (h marathonHeap) Less(i, j int) bool  { return h[j].better(h[i]) }
+func (h marathonHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *marathonHeap) Push(x interface{}) { *h = append(*h, x.(*Marathon)) }
+
+func (h *marathonHeap) Pop() interface{} {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
+
+// marathonTheme checks the films meet the constraint, returning what they
+// have in common. A theme is a TMDB keyword, genres are too broad to be one
+func marathonTheme(films []*Movie, constraint string) (string, bool) {
+	switch constraint {
+	case MarathonSameDirector, MarathonSameTheme:
+		shared := films[0].Keywords
+		if constraint == MarathonSameDirector {
+			shared = films[0].Directors
+		}
+		for _, f := range films[1:] {
+			other := f.Keywords
+			if constraint == MarathonSameDirector {
+				other = f.Directors
+			}
+			shared = Intersection(shared, other)
+		}
+		return strings.Join(shared, ", "), len(shared) > 0
+	case MarathonDifferentGenres:
+		seen := map[string]bool{}
+		for _, f := range films {
+			for _, g := range f.Genres {
+				if seen[g] {
+					return "", false
+				}
+			}
+			for _, g := range f.Genres {
+				seen[g] = true
+			}
+		}
+	}
+	return "", true
+}
+
+// PlanMarathons finds the sets of Count films that fit in the budget, with a
+// Break between each, and meet the constraint. Plans are best first by the
+// films' average Score, then by the least spare time. Films in a plan are in
+// release order
+func PlanMarathons(movies []*Movie, opts MarathonOpts) ([]*Marathon, error) {
+	if opts.Budget <= 0 {
+		return nil, errors.New("a marathon needs a time budget")
+	}
+	if opts.Count < 1 {
+		return nil, errors.New("a marathon needs at least 1 film")
+	}
+	switch opts.Constraint {
+	case MarathonAny, MarathonSameDirector, MarathonSameTheme, MarathonDifferentGenres:
+	default:
+		return nil, fmt.Errorf("unknown marathon constraint: %v", opts.Constraint)
+	}
+	if opts.Plans <= 0 {
+		opts.Plans = DefaultMarathonPlans
+	}
+	breaks := opts.Break * time.Duration(opts.Count-1)
+	filmBudget := opts.Budget - breaks
+
+	// Films without a runtime can't be planned around. The best are tried
+	// first, so a branch stops as soon as even the best films left can't
+	// beat the worst plan kept
+	candidates := []*Movie{}
+	for _, m := range movies {
+		if m.RunTime > 0 && m.RunTime <= filmBudget {
+			candidates = append(candidates, m)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score() > candidates[j].Score()
+	})
+	// best[i] is the total Score of candidates i onwards, so the best k films
+	// from i are best[i] - best[i+k]
+	best := make([]float64, len(candidates)+1)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		best[i] = best[i+1] + candidates[i].Score()
+	}
+
+	plans := &marathonHeap{}
+	found := 0
+	picked := []*Movie{}
+	var search func(from int, runtime time.Duration, score float64)
+	search = func(from int, runtime time.Duration, score float64) {
+		if len(picked) == opts.Count {
+			theme, ok := marathonTheme(picked, opts.Constraint)
+			if !ok {
+				return
+			}
+			films := append([]*Movie{}, picked...)
+			sort.SliceStable(films, func(i, j int) bool {
+				return films[i].ReleaseYear < films[j].ReleaseYear
+			})
+			plan := &Marathon{
+				Films:   films,
+				Runtime: runtime,
+				Total:   runtime + breaks,
+				Spare:   opts.Budget - runtime - breaks,
+				Theme:   theme,
+				avg:     score / float64(opts.Count),
+				found:   found,
+			}
+			found++
+			if plans.Len() < opts.Plans {
+				heap.Push(plans, plan)
+			} else if plan.better((*plans)[0]) {
+				(*plans)[0] = plan
+				heap.Fix(plans, 0)
+			}
+			return
+		}
+		need := opts.Count - len(picked)
+		for i := from; i+need <= len(candidates); i++ {
+			// The bound is summed in a different order to avg, so a little
+			// slack keeps rounding from cutting off a plan just as good
+			if plans.Len() == opts.Plans && (score+best[i]-best[i+need])/float64(opts.Count) < (*plans)[0].avg-1e-9 {
+				return
+			}
+			if runtime+candidates[i].RunTime > filmBudget {
+				continue
+			}
+			picked = append(picked, candidates[i])
+			search(i+1, runtime+candidates[i].RunTime, score+candidates[i].Score())
+			picked = picked[:len(picked)-1]
+		}
+	}
+	search(0, 0, 0)
+
+	ret := make([]*Marathon, plans.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(plans).(*Marathon)
+	}
+	return ret, nil
+}
@@ -0,0 +1,117 @@
+package letswatch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeUntil(t *testing.T) {
+	now := time.Date(2022, 10, 14, 19, 42, 30, 0, time.UTC)
+	tests := map[string]struct {
+		start   string
+		endsBy  string
+		want    time.Duration
+		wantErr string
+	}{
+		"from-now":      {endsBy: "23:00", want: 3*time.Hour + 18*time.Minute},
+		"start":         {start: "20:15", endsBy: "23:00", want: 2*time.Hour + 45*time.Minute},
+		"past-midnight": {start: "22:30", endsBy: "1am", want: 2*time.Hour + 30*time.Minute},
+		"kitchen":       {start: "8:15pm", endsBy: "11 PM", want: 2*time.Hour + 45*time.Minute},
+		"already-past":  {endsBy: "1900", want: 23*time.Hour + 18*time.Minute},
+		"same":          {start: "21:00", endsBy: "21:00", wantErr: "nothing fits between 21:00 and 21:00"},
+		"bad":           {endsBy: "late", wantErr: "invalid time of day: late, use something like 23:00 or 11pm"},
+	}
+	for k, tt := range tests {
+		got, err := RuntimeUntil(tt.start, tt.endsBy, now)
+		if tt.wantErr != "" {
+			require.EqualError(t, err, tt.wantErr, k)
+			continue
+		}
+		require.NoError(t, err, k)
+		require.Equal(t, tt.want, got, k)
+	}
+}
+
+func marathonTestMovies() []*Movie {
+	return []*Movie{
+		{Title: "Cure", ReleaseYear: 1997, RunTime: 111 * time.Minute, Rating: 7.6, Directors: []string{"Kiyoshi Kurosawa"}, Genres: []string{"Crime", "Horror"}, Keywords: []string{"detective", "hypnosis"}},
+		{Title: "Pulse", ReleaseYear: 2001, RunTime: 119 * time.Minute, Rating: 6.5, Directors: []string{"Kiyoshi Kurosawa"}, Genres: []string{"Horror"}, Keywords: []string{"ghost", "internet"}},
+		{Title: "Alien", ReleaseYear: 1979, RunTime: 117 * time.Minute, Rating: 8.5, Directors: []string{"Ridley Scott"}, Genres: []string{"Horror", "Science Fiction"}, Keywords: []string{"space", "alien"}},
+		{Title: "Paddington 2", ReleaseYear: 2017, RunTime: 103 * time.Minute, Rating: 7.6, Directors: []string{"Paul King"}, Genres: []string{"Comedy", "Family"}, Keywords: []string{"prison", "detective"}},
+		{Title: "Barry Lyndon", ReleaseYear: 1975, RunTime: 185 * time.Minute, Rating: 8.1, Directors: []string{"Stanley Kubrick"}, Genres: []string{"Drama"}},
+		{Title: "Unknown", ReleaseYear: 2020},
+	}
+}
+
+func planTitles(plans []*Marathon) [][]string {
+	ret := [][]string{}
+	for _, p := range plans {
+		ret = append(ret, movieTitles(p.Films))
+	}
+	return ret
+}
+
+func TestPlanMarathons(t *testing.T) {
+	movies := marathonTestMovies()
+	plans, err := PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2, Plans: 10})
+	require.NoError(t, err)
+	// Best rated first, in release order, and Barry Lyndon never fits
+	require.Equal(t, []string{"Alien", "Cure"}, planTitles(plans)[0])
+	require.Len(t, plans, 6)
+	require.Equal(t, 228*time.Minute, plans[0].Runtime)
+	require.Equal(t, 12*time.Minute, plans[0].Spare)
+	// Only the best are kept, the same as the top of the full list
+	top, err := PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2})
+	require.NoError(t, err)
+	require.Equal(t, planTitles(plans)[:DefaultMarathonPlans], planTitles(top))
+
+	// A break between them leaves less room
+	plans, err = PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2, Break: 15 * time.Minute, Plans: 3})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"Alien", "Paddington 2"}, {"Cure", "Paddington 2"}, {"Pulse", "Paddington 2"}}, planTitles(plans))
+	require.Equal(t, 235*time.Minute, plans[0].Total)
+
+	plans, err = PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2, Constraint: MarathonSameDirector})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"Cure", "Pulse"}}, planTitles(plans))
+	require.Equal(t, "Kiyoshi Kurosawa", plans[0].Theme)
+
+	plans, err = PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2, Constraint: MarathonSameTheme, Plans: 1})
+	require.NoError(t, err)
+	// Sharing a genre isn't enough
+	require.Equal(t, [][]string{{"Cure", "Paddington 2"}}, planTitles(plans))
+	require.Equal(t, "detective", plans[0].Theme)
+
+	plans, err = PlanMarathons(movies, MarathonOpts{Budget: 4 * time.Hour, Count: 2, Constraint: MarathonDifferentGenres})
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"Alien", "Paddington 2"}, {"Cure", "Paddington 2"}, {"Pulse", "Paddington 2"}}, planTitles(plans))
+
+	// Cutting off branches finds the same plans as trying everything
+	many := []*Movie{}
+	for i := 0; i < 30; i++ {
+		many = append(many, &Movie{
+			Title:       fmt.Sprint(i),
+			RunTime:     time.Duration(80+i*37%70) * time.Minute,
+			Rating:      float64(i*7%10) / 2,
+			Directors:   []string{fmt.Sprint(i % 4)},
+			ReleaseYear: 2000 + i,
+		})
+	}
+	for _, constraint := range []string{MarathonAny, MarathonSameDirector} {
+		opts := MarathonOpts{Budget: 6 * time.Hour, Count: 3, Constraint: constraint, Plans: 5000}
+		all, err := PlanMarathons(many, opts)
+		require.NoError(t, err)
+		opts.Plans = 5
+		plans, err = PlanMarathons(many, opts)
+		require.NoError(t, err)
+		require.Equal(t, planTitles(all)[:5], planTitles(plans), constraint)
+	}
+
+	_, err = PlanMarathons(movies, MarathonOpts{Count: 2})
+	require.EqualError(t, err, "a marathon needs a time budget")
+	_, err = PlanMarathons(movies, MarathonOpts{Budget: time.Hour, Count: 2, Constraint: "same-star"})
+	require.EqualError(t, err, "unknown marathon constraint: same-star")
+}
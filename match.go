@@ -0,0 +1,280 @@
+package letswatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultMatchThreshold is the confidence a title search needs before the film
+// it found is used
+const DefaultMatchThreshold = 0.8
+
+// matchCandidates is how many search results are scored
+const matchCandidates = 5
+
+// How much each thing a candidate is scored on counts. Those that can't be
+// compared, like the year of a film with no year, are left out
+const (
+	matchTitleWeight    = 0.6
+	matchYearWeight     = 0.25
+	matchDirectorWeight = 0.15
+)
+
+// matchYearScores are the year scores by how many years apart the films are
+var matchYearScores = []float64{1, 0.8, 0.5, 0.25}
+
+// accentReplacer folds the common Latin accents, so "Amélie" matches "Amelie"
+var accentReplacer = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "æ", "ae",
+	"ç", "c", "è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i", "ñ", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "œ", "oe",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ý", "y", "ÿ", "y", "ß", "ss",
+)
+
+// titleArticles are dropped from the start or end of a title, so "The
+// Handmaiden" and "Handmaiden, The" are the same
+var titleArticles = map[string]bool{"the": true, "a": true, "an": true}
+
+// TitleMatch is a TMDB film scored against a FilmRef
+type TitleMatch struct {
+	TMDBID        int64    `yaml:"tmdb_id" json:"tmdb_id"`
+	Title         string   `yaml:"title" json:"title"`
+	OriginalTitle string   `yaml:"original_title,omitempty" json:"original_title,omitempty"`
+	Year          int      `yaml:"year,omitempty" json:"year,omitempty"`
+	Directors     []string `yaml:"directors,omitempty" json:"directors,omitempty"`
+	// MatchedTitle is the closest of the film's titles, which may be an
+	// alternative one
+	MatchedTitle string  `yaml:"matched_title" json:"matched_title"`
+	Confidence   float64 `yaml:"confidence" json:"confidence"`
+	popularity   float32
+}
+
+// UnresolvedError is returned when no film found is a confident enough match
+type UnresolvedError struct {
+	Ref  FilmRef
+	Best *TitleMatch
+}
+
+func (e *UnresolvedError) Error() string {
+	if e.Best == nil {
+		return fmt.Sprintf("no film found on TMDB for %v", e.Ref)
+	}
+	return fmt.Sprintf("no confident match on TMDB for %v, the closest was %v (%v) at %.0f%%",
+		e.Ref, e.Best.Title, e.Best.Year, e.Best.Confidence*100)
+}
+
+// MatchThreshold is the configured match threshold, or DefaultMatchThreshold
+func (c *Client) MatchThreshold() float64 {
+	if c.Config == nil || c.Config.MatchThreshold <= 0 {
+		return DefaultMatchThreshold
+	}
+	return c.Config.MatchThreshold
+}
+
+// MatchTitle searches TMDB for the ref's title and scores what it finds on
+// the title, original and alternative titles, year and directors. Matches are
+// best first
+func (c *Client) MatchTitle(ctx context.Context, ref FilmRef) ([]*TitleMatch, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Release years differ between countries, so films from other years are
+	// searched for too
+	results, err := c.TMDB.SearchMovies(ctx, ref.Title, ref.Year)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Year > 0 {
+		all, err := c.TMDB.SearchMovies(ctx, ref.Title, 0)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[int64]bool{}
+		for _, r := range results {
+			seen[r.ID] = true
+		}
+		for _, r := range all {
+			if !seen[r.ID] {
+				results = append(results, r)
+			}
+		}
+	}
+	if len(results) > matchCandidates {
+		results = results[:matchCandidates]
+	}
+
+	want := normalizeTitle(ref.Title)
+	matches := []*TitleMatch{}
+	for _, r := range results {
+		match := &TitleMatch{
+			TMDBID:        r.ID,
+			Title:         r.Title,
+			OriginalTitle: r.OriginalTitle,
+			Year:          r.ReleaseYear,
+			popularity:    r.Popularity,
+		}
+		titleScore := match.bestTitle(want, r.Title, r.OriginalTitle)
+		// The details have the alternative titles and directors, only look
+		// them up if they could change the score
+		if titleScore < 1 || len(ref.Directors) > 0 {
+			if details, err := c.TMDB.GetWithTMDBID(ctx, int(r.ID)); err != nil {
+				log.Debug().Err(err).Int64("tmdb", r.ID).Msg("Error looking up details, scoring on the search result")
+			} else {
+				titles := []string{}
+				if details.MovieAlternativeTitlesAppend != nil && details.AlternativeTitles != nil {
+					for _, t := range details.AlternativeTitles.Titles {
+						titles = append(titles, t.Title)
+					}
+				}
+				titleScore = match.bestTitle(want, titles...)
+				match.Directors = NewMovieWithTMDB(details).Directors
+				if match.Directors == nil {
+					match.Directors = []string{}
+				}
+			}
+		}
+
+		score := matchTitleWeight * titleScore
+		weight := matchTitleWeight
+		if ref.Year > 0 {
+			score += matchYearWeight * yearScore(ref.Year, r.ReleaseYear)
+			weight += matchYearWeight
+		}
+		// A nil Directors means the details weren't looked up, not that the
+		// film has none
+		if len(ref.Directors) > 0 && match.Directors != nil {
+			if sameDirector(ref.Directors, match.Directors) {
+				score += matchDirectorWeight
+			}
+			weight += matchDirectorWeight
+		}
+		match.Confidence = score / weight
+		matches = append(matches, match)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Confidence != matches[j].Confidence {
+			return matches[i].Confidence > matches[j].Confidence
+		}
+		return matches[i].popularity > matches[j].popularity
+	})
+	return matches, nil
+}
+
+// MatchFilm is the best MatchTitle match, or an UnresolvedError if it isn't
+// above the threshold
+func (c *Client) MatchFilm(ctx context.Context, ref FilmRef) (*TitleMatch, error) {
+	matches, err := c.MatchTitle(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, &UnresolvedError{Ref: ref}
+	}
+	if matches[0].Confidence < c.MatchThreshold() {
+		return nil, &UnresolvedError{Ref: ref, Best: matches[0]}
+	}
+	return matches[0], nil
+}
+
+// bestTitle sets MatchedTitle to whichever of titles is closest to want,
+// returning how close it is. A title already matched stays unless one of the
+// new ones is closer
+func (m *TitleMatch) bestTitle(want string, titles ...string) float64 {
+	best := 0.0
+	if m.MatchedTitle != "" {
+		best = titleSimilarity(want, normalizeTitle(m.MatchedTitle))
+	}
+	for _, t := range titles {
+		if s := titleSimilarity(want, normalizeTitle(t)); s > best || m.MatchedTitle == "" {
+			best = s
+			m.MatchedTitle = t
+		}
+	}
+	return best
+}
+
+// normalizeTitle lowercases a title and drops accents, punctuation and
+// leading or trailing articles
+func normalizeTitle(title string) string {
+	title = accentReplacer.Replace(strings.ToLower(title))
+	title = strings.ReplaceAll(title, "&", " and ")
+	words := strings.FieldsFunc(title, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) > 1 && titleArticles[words[0]] {
+		words = words[1:]
+	}
+	if len(words) > 1 && titleArticles[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+// titleSimilarity is 1 for the same titles, down to 0 for ones with nothing
+// in common, by edit distance
+func titleSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein is the number of single character edits between a and b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// yearScore is how close two release years are. A film with no year is
+// neither near nor far
+func yearScore(want, got int) float64 {
+	if got == 0 {
+		return 0.5
+	}
+	diff := want - got
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff >= len(matchYearScores) {
+		return 0
+	}
+	return matchYearScores[diff]
+}
+
+// sameDirector is true if any of the directors are in both lists
+func sameDirector(want, got []string) bool {
+	for _, w := range want {
+		for _, g := range got {
+			if normalizeTitle(w) == normalizeTitle(g) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := map[string]string{
+		"The Handmaiden":                "handmaiden",
+		"Handmaiden, The":               "handmaiden",
+		"Amélie":                        "amelie",
+		"Fast & Furious":                "fast and furious",
+		"Mission: Impossible – Fallout": "mission impossible fallout",
+		"The":                           "the",
+	}
+	for title, want := range tests {
+		require.Equal(t, want, normalizeTitle(title), title)
+	}
+	require.Equal(t, 1.0, titleSimilarity("handmaiden", "handmaiden"))
+	require.InDelta(t, 0.9, titleSimilarity("handmaiden", "handmaidan"), 0.001)
+	require.Equal(t, 0.0, titleSimilarity("", ""))
+}
+
+func TestMatchTitle(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c := newResolveTestClient(t, false)
+	for id, file := range map[string]string{"290098": "movie_details_match.json", "1022": "movie_details_1022.json"} {
+		details, err := ioutil.ReadFile("testdata/" + file)
+		require.NoError(t, err)
+		httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/movie/"+id,
+			httpmock.NewStringResponder(200, string(details)))
+	}
+	ctx := context.Background()
+
+	// An alternative title is as good as the main one
+	match, err := c.MatchFilm(ctx, FilmRef{Title: "Agassi", Year: 2016})
+	require.NoError(t, err)
+	require.Equal(t, int64(290098), match.TMDBID)
+	require.Equal(t, "Agassi", match.MatchedTitle)
+	require.Equal(t, 1.0, match.Confidence)
+
+	// The director picks between films of the same name
+	match, err = c.MatchFilm(ctx, FilmRef{Title: "Handmaiden", Year: 1995, Directors: []string{"Jane Doe"}})
+	require.NoError(t, err)
+	require.Equal(t, int64(1022), match.TMDBID)
+	require.InDelta(t, 0.95, match.Confidence, 0.001)
+	matches, err := c.MatchTitle(ctx, FilmRef{Title: "The Handmaiden", Directors: []string{"Park Chan-wook"}})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	require.Equal(t, int64(290098), matches[0].TMDBID)
+	require.Equal(t, []string{"Park Chan-wook"}, matches[0].Directors)
+	require.Greater(t, matches[0].Confidence, matches[1].Confidence)
+
+	// Below the threshold the film is unresolved, with the closest match
+	c.Config.MatchThreshold = 0.99
+	_, err = c.MatchFilm(ctx, FilmRef{Title: "The Handmaiden", Year: 2017})
+	var unresolved *UnresolvedError
+	require.ErrorAs(t, err, &unresolved)
+	require.Equal(t, int64(290098), unresolved.Best.TMDBID)
+	require.InDelta(t, 0.941, unresolved.Best.Confidence, 0.001)
+}
@@ -0,0 +1,38 @@
+package letswatch
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MediaServer is a library we own, like Plex, Jellyfin or Emby. Anything on
+// one of these counts as available to me
+type MediaServer interface {
+	Name() string
+	IsAvailable(context.Context, string, int) (bool, error)
+}
+
+// Names of the supported media servers
+const (
+	MediaServerPlex     = "plex"
+	MediaServerJellyfin = "jellyfin"
+	MediaServerEmby     = "emby"
+)
+
+// AvailableOn returns the names of the configured media servers that have the
+// given film. A server that errors is logged and treated as not having it
+func (c *Client) AvailableOn(ctx context.Context, title string, year int) []string {
+	ret := []string{}
+	for _, s := range c.MediaServers {
+		avail, err := s.IsAvailable(ctx, title, year)
+		if err != nil {
+			log.Warn().Err(err).Str("server", s.Name()).Str("title", title).Msg("Error checking media server")
+			continue
+		}
+		if avail {
+			ret = append(ret, s.Name())
+		}
+	}
+	return ret
+}
@@ -2,27 +2,73 @@ package letswatch
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
 type Movie struct {
-	Title         string        `yaml:"title,omitempty"`
-	Directors     []string      `yaml:"directors,omitempty"`
-	ReleaseYear   int           `yaml:"release_year,omitempty"`
-	IMDBID        string        `yaml:"imdb_id,omitempty"`
-	IMDBLink      string        `yaml:"imdb_link,omitempty"`
-	TMDBID        string        `yaml:"tmdb_id,omitempty"`
-	Language      string        `yaml:"language,omitempty"`
-	OnPlex        bool          `yaml:"on_plex,omitempty"`
-	RunTime       time.Duration `yaml:"runtime,omitempty"`
-	StreamingOn   []string      `yaml:"streaming_on,omitempty"`
-	StreamingOnMy []string      `yaml:"streaming_on_my,omitempty"`
-	Genres        []string      `yaml:"genres,omitempty"`
-	Budget        float64       `yaml:"budget,omitempty"`
+	Title         string        `yaml:"title,omitempty" json:"title,omitempty"`
+	Directors     []string      `yaml:"directors,omitempty" json:"directors,omitempty"`
+	ReleaseYear   int           `yaml:"release_year,omitempty" json:"release_year,omitempty"`
+	IMDBID        string        `yaml:"imdb_id,omitempty" json:"imdb_id,omitempty"`
+	IMDBLink      string        `yaml:"imdb_link,omitempty" json:"imdb_link,omitempty"`
+	TMDBID        string        `yaml:"tmdb_id,omitempty" json:"tmdb_id,omitempty"`
+	Language      string        `yaml:"language,omitempty" json:"language,omitempty"`
+	OnPlex        bool          `yaml:"on_plex,omitempty" json:"on_plex,omitempty"`
+	AvailableOn   []string      `yaml:"available_on,omitempty" json:"available_on,omitempty"`
+	RunTime       time.Duration `yaml:"runtime,omitempty" json:"runtime,omitempty"`
+	StreamingOn   []string      `yaml:"streaming_on,omitempty" json:"streaming_on,omitempty"`
+	StreamingOnMy []string      `yaml:"streaming_on_my,omitempty" json:"streaming_on_my,omitempty"`
+	Genres        []string      `yaml:"genres,omitempty" json:"genres,omitempty"`
+	Budget        float64       `yaml:"budget,omitempty" json:"budget,omitempty"`
+	Rating        float64       `yaml:"rating,omitempty" json:"rating,omitempty"`
+	Overview      string        `yaml:"overview,omitempty" json:"overview,omitempty"`
+	Cast          []string      `yaml:"cast,omitempty" json:"cast,omitempty"`
+	// Keywords are TMDB's tags for what the film is about, like "heist"
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+	// PosterPath is the TMDB poster image, for PosterService
+	PosterPath string `yaml:"poster_path,omitempty" json:"poster_path,omitempty"`
+	// RequestStatus is where the film is in the request backend, such as Radarr
+	RequestStatus string `yaml:"request_status,omitempty" json:"request_status,omitempty"`
+	// WatchedOn is where a watched film was seen as watched, such as
+	// letterboxd or plex. Only set when watched films are included
+	WatchedOn []string `yaml:"watched_on,omitempty" json:"watched_on,omitempty"`
+}
+
+// LetterboxdLink is the film page on Letterboxd, found through its TMDB ID
+func (m *Movie) LetterboxdLink() string {
+	if m.TMDBID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://letterboxd.com/tmdb/%v", m.TMDBID)
+}
+
+// TMDBLink is the film page on TMDB
+func (m *Movie) TMDBLink() string {
+	if m.TMDBID == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://www.themoviedb.org/movie/%v", m.TMDBID)
+}
+
+// Film converts the movie back in to a Letterboxd film, for the request
+// backends
+func (m *Movie) Film() *letterboxd.Film {
+	return &letterboxd.Film{
+		Title: m.Title,
+		Year:  m.ReleaseYear,
+		ExternalIDs: &letterboxd.FilmExternalIDs{
+			IMDB: m.IMDBID,
+			TMDB: m.TMDBID,
+		},
+	}
 }
 
 type MovieFilterOpts struct {
@@ -37,6 +83,8 @@ type MovieFilterOpts struct {
 	OnlyNotMyStreaming  bool          `yaml:"only_not_my_streaming,omitempty"`
 	Genres              []string      `yaml:"genre,omitempty"`
 	Directors           []string      `yaml:"directors,omitempty"`
+	// OnlyNew skips films that have been recommended on a previous run
+	OnlyNew bool `yaml:"only_new,omitempty"`
 }
 
 func (m *MovieFilterOpts) ValidateWithPerson(p *PersonInfo) error {
@@ -53,6 +101,90 @@ func (m *MovieFilterOpts) ValidateWithPerson(p *PersonInfo) error {
 	return nil
 }
 
+// MatchesYear returns true if the release year isn't before Earliest
+func (m *MovieFilterOpts) MatchesYear(year int) bool {
+	return m.Earliest == 0 || year >= m.Earliest
+}
+
+// Matches returns true if the movie passes the filters that only need TMDB
+// data. Streaming filters need extra lookups, so are not checked here
+func (m *MovieFilterOpts) Matches(movie *Movie) bool {
+	slog := log.With().Str("film", movie.Title).Logger()
+	if !m.MatchesYear(movie.ReleaseYear) {
+		slog.Debug().Int("year", movie.ReleaseYear).Msg("Outside of the release years we want")
+		return false
+	}
+	if len(m.Directors) > 0 && len(Intersection(m.Directors, movie.Directors)) == 0 {
+		slog.Debug().Strs("directors", movie.Directors).Strs("want-directors", m.Directors).Msg("Film does not have any of the directors we want")
+		return false
+	}
+
+	// Filter based on language
+	if m.Language != "" && movie.Language != m.Language {
+		slog.Debug().Str("language", movie.Language).Msg("Wrong language")
+		return false
+	}
+
+	if m.MaxRuntime != 0 && movie.RunTime > m.MaxRuntime {
+		slog.Debug().Str("runtime", fmt.Sprint(movie.RunTime)).Str("max-time", fmt.Sprint(m.MaxRuntime)).Msg("Too long")
+		return false
+	}
+	if m.MinRuntime != 0 && movie.RunTime < m.MinRuntime {
+		slog.Debug().Str("runtime", fmt.Sprint(movie.RunTime)).Str("min-time", fmt.Sprint(m.MinRuntime)).Msg("Too short")
+		return false
+	}
+
+	if len(m.Genres) > 0 && len(Intersection(m.Genres, movie.Genres)) == 0 {
+		slog.Debug().Strs("genres", movie.Genres).Strs("want-genres", m.Genres).Msg("Film does not have any of the genres we want")
+		return false
+	}
+	return true
+}
+
+// NewMovieFilterOptsWithValues builds filter options from URL query values,
+// using the same names and defaults as the command line flags
+func NewMovieFilterOptsWithValues(q url.Values) (*MovieFilterOpts, error) {
+	opts := &MovieFilterOpts{
+		Earliest:   1900,
+		MinRuntime: 15 * time.Minute,
+		Language:   q.Get("language"),
+		Genres:     q["genre"],
+		Directors:  q["director"],
+	}
+	var err error
+	if v := q.Get("earliest"); v != "" {
+		if opts.Earliest, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("invalid earliest: %v", v)
+		}
+	}
+	for name, dest := range map[string]*time.Duration{
+		"max-runtime": &opts.MaxRuntime,
+		"min-runtime": &opts.MinRuntime,
+	} {
+		if v := q.Get(name); v != "" {
+			if *dest, err = time.ParseDuration(v); err != nil {
+				return nil, fmt.Errorf("invalid %v: %v", name, v)
+			}
+		}
+	}
+	for name, dest := range map[string]*bool{
+		"include-watched":       &opts.IncludeWatched,
+		"only-my-streaming":     &opts.OnlyMyStreaming,
+		"only-not-my-streaming": &opts.OnlyNotMyStreaming,
+		"only-new":              &opts.OnlyNew,
+	} {
+		if v := q.Get(name); v != "" {
+			if *dest, err = strconv.ParseBool(v); err != nil {
+				return nil, fmt.Errorf("invalid %v: %v", name, v)
+			}
+		}
+	}
+	if err = opts.applyEndsBy(q.Get("start"), q.Get("ends-by"), time.Now()); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
 func NewMovieFilterOptsWithCmd(cmd *cobra.Command) (*MovieFilterOpts, error) {
 	opts := &MovieFilterOpts{}
 	earliest, err := cmd.Flags().GetInt("earliest")
@@ -90,12 +222,40 @@ func NewMovieFilterOptsWithCmd(cmd *cobra.Command) (*MovieFilterOpts, error) {
 
 	opts.IncludeWatched, _ = cmd.Flags().GetBool("include-watched")
 
+	opts.OnlyNew, _ = cmd.Flags().GetBool("only-new")
+
+	start, _ := cmd.Flags().GetString("start")
+	endsBy, _ := cmd.Flags().GetString("ends-by")
+	if err := opts.applyEndsBy(start, endsBy, time.Now()); err != nil {
+		return nil, err
+	}
+
 	return opts, nil
 }
 
 type MovieCollectOpts struct {
 	Watchlist bool                 `yaml:"use_watchlist,omitempty"`
 	Lists     []*letterboxd.ListID `yaml:"lists,omitempty"`
+	// JSONLists are files or URLs of StevenLu/Radarr style JSON lists
+	JSONLists []string `yaml:"json_lists,omitempty"`
+}
+
+// NewMovieCollectOptsWithValues builds collect options from URL query values,
+// using the same names as the command line flags
+func NewMovieCollectOptsWithValues(q url.Values) (*MovieCollectOpts, error) {
+	opts := &MovieCollectOpts{}
+	var err error
+	if v := q.Get("watchlist"); v != "" {
+		if opts.Watchlist, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid watchlist: %v", v)
+		}
+	}
+	opts.Lists, err = parseListArgs(q["list"])
+	if err != nil {
+		return nil, err
+	}
+	opts.JSONLists = q["json-list"]
+	return opts, nil
 }
 
 func NewMovieCollectOptsWithCmd(cmd *cobra.Command) (*MovieCollectOpts, error) {
@@ -103,6 +263,8 @@ func NewMovieCollectOptsWithCmd(cmd *cobra.Command) (*MovieCollectOpts, error) {
 	var err error
 	opts.Watchlist, _ = cmd.Flags().GetBool("watchlist")
 
+	opts.JSONLists, _ = cmd.Flags().GetStringArray("json-list")
+
 	listArg, err := cmd.Flags().GetStringArray("list")
 	if err != nil {
 		opts.Lists = []*letterboxd.ListID{}
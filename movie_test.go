@@ -0,0 +1,58 @@
+package letswatch
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMovieFilterOptsWithValues(t *testing.T) {
+	got, err := NewMovieFilterOptsWithValues(url.Values{
+		"earliest":          {"1960"},
+		"max-runtime":       {"2h"},
+		"genre":             {"Horror", "Comedy"},
+		"only-my-streaming": {"true"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &MovieFilterOpts{
+		Earliest:        1960,
+		MaxRuntime:      2 * time.Hour,
+		MinRuntime:      15 * time.Minute,
+		Genres:          []string{"Horror", "Comedy"},
+		OnlyMyStreaming: true,
+	}, got)
+
+	_, err = NewMovieFilterOptsWithValues(url.Values{"earliest": {"soon"}})
+	require.EqualError(t, err, "invalid earliest: soon")
+}
+
+func TestMovieFilterOptsMatches(t *testing.T) {
+	movie := &Movie{
+		Title:       "The Handmaiden",
+		ReleaseYear: 2016,
+		Directors:   []string{"Park Chan-wook"},
+		Language:    "ko",
+		RunTime:     145 * time.Minute,
+		Genres:      []string{"Thriller", "Drama", "Romance"},
+	}
+	tests := map[string]struct {
+		filter MovieFilterOpts
+		want   bool
+	}{
+		"empty":          {filter: MovieFilterOpts{}, want: true},
+		"too-new":        {filter: MovieFilterOpts{Earliest: 2020}, want: false},
+		"too-long":       {filter: MovieFilterOpts{MaxRuntime: 2 * time.Hour}, want: false},
+		"too-short":      {filter: MovieFilterOpts{MinRuntime: 3 * time.Hour}, want: false},
+		"language":       {filter: MovieFilterOpts{Language: "ko"}, want: true},
+		"wrong-language": {filter: MovieFilterOpts{Language: "en"}, want: false},
+		"genre":          {filter: MovieFilterOpts{Genres: []string{"Horror", "Drama"}}, want: true},
+		"wrong-genre":    {filter: MovieFilterOpts{Genres: []string{"Horror"}}, want: false},
+		"director":       {filter: MovieFilterOpts{Directors: []string{"Park Chan-wook"}}, want: true},
+		"wrong-director": {filter: MovieFilterOpts{Directors: []string{"Bong Joon-ho"}}, want: false},
+	}
+	for k, tt := range tests {
+		require.Equal(t, tt.want, tt.filter.Matches(movie), k)
+	}
+}
@@ -0,0 +1,328 @@
+package letswatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// Events that notifiers can subscribe to
+const (
+	EventFilmsAdded         = "films-added"
+	EventNewRecommendations = "new-recommendations"
+	EventRunFailed          = "run-failed"
+)
+
+// Kinds of notifier
+const (
+	NotifierWebhook = "webhook"
+	NotifierDiscord = "discord"
+	NotifierSlack   = "slack"
+	NotifierNtfy    = "ntfy"
+	NotifierGotify  = "gotify"
+	NotifierSMTP    = "smtp"
+)
+
+// Notification is a single event to send out
+type Notification struct {
+	Event   string   `json:"event"`
+	Title   string   `json:"title"`
+	Message string   `json:"message,omitempty"`
+	Films   []string `json:"films,omitempty"`
+}
+
+// Text is the notification as plain text, for notifiers that don't take
+// structured payloads
+func (n *Notification) Text() string {
+	parts := []string{}
+	if n.Message != "" {
+		parts = append(parts, n.Message)
+	}
+	for _, f := range n.Films {
+		parts = append(parts, "- "+f)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// NewFilmsNotification returns a notification for the given event, listing
+// each film as 'Title (Year)'
+func NewFilmsNotification(event, title string, films []*letterboxd.Film) *Notification {
+	n := &Notification{
+		Event: event,
+		Title: title,
+	}
+	for _, f := range films {
+		n.Films = append(n.Films, filmDisplayName(f.Title, f.Year))
+	}
+	return n
+}
+
+// NewRunFailedNotification returns a notification for a failed run of name
+func NewRunFailedNotification(name string, err error) *Notification {
+	return &Notification{
+		Event:   EventRunFailed,
+		Title:   fmt.Sprintf("letswatch %v failed", name),
+		Message: err.Error(),
+	}
+}
+
+func filmDisplayName(title string, year int) string {
+	if year > 0 {
+		return fmt.Sprintf("%v (%v)", title, year)
+	}
+	return title
+}
+
+// NotifierConfig is a single notifier, as defined under 'notifiers' in the
+// config
+type NotifierConfig struct {
+	Name string `mapstructure:"name"`
+	Kind string `mapstructure:"kind"`
+	// Events this notifier is sent. Empty means all of them
+	Events []string `mapstructure:"events"`
+	// URL is the webhook, ntfy topic or Gotify server URL
+	URL string `mapstructure:"url"`
+	// Token is the ntfy access token or Gotify app token
+	Token string `mapstructure:"token"`
+	// SMTP settings
+	Host     string   `mapstructure:"host"`
+	Port     int      `mapstructure:"port"`
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+}
+
+// Wants returns true if the notifier is subscribed to the event
+func (n *NotifierConfig) Wants(event string) bool {
+	return len(n.Events) == 0 || ContainsString(n.Events, event)
+}
+
+// Notifier sends a notification somewhere
+type Notifier interface {
+	Notify(context.Context, *Notification) error
+}
+
+// NotifyService sends notifications to every configured notifier that wants
+// them
+type NotifyService interface {
+	Send(context.Context, *Notification) error
+}
+
+type NotifyServiceOp struct {
+	client    *Client
+	configs   []NotifierConfig
+	notifiers []Notifier
+}
+
+func newNotifyService(c *Client, configs []NotifierConfig) (*NotifyServiceOp, error) {
+	svc := &NotifyServiceOp{client: c}
+	for _, nc := range configs {
+		n, err := newNotifier(c, nc)
+		if err != nil {
+			return nil, err
+		}
+		svc.configs = append(svc.configs, nc)
+		svc.notifiers = append(svc.notifiers, n)
+	}
+	return svc, nil
+}
+
+func newNotifier(c *Client, nc NotifierConfig) (Notifier, error) {
+	switch nc.Kind {
+	case NotifierWebhook, NotifierDiscord, NotifierSlack, NotifierNtfy, NotifierGotify:
+		if nc.URL == "" {
+			return nil, fmt.Errorf("notifier %v needs a url", nc.Name)
+		}
+		return &webhookNotifier{client: c, kind: nc.Kind, url: nc.URL, token: nc.Token}, nil
+	case NotifierSMTP:
+		if nc.Host == "" || nc.From == "" || len(nc.To) == 0 {
+			return nil, fmt.Errorf("notifier %v needs a host, from and to", nc.Name)
+		}
+		return &smtpNotifier{config: nc, send: sendMail}, nil
+	default:
+		return nil, fmt.Errorf("notifier %v has unknown kind: %v", nc.Name, nc.Kind)
+	}
+}
+
+// Send sends n to every notifier subscribed to its event. A failing notifier
+// does not stop the others
+func (svc *NotifyServiceOp) Send(ctx context.Context, n *Notification) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	errs := []string{}
+	for i, nc := range svc.configs {
+		if !nc.Wants(n.Event) {
+			continue
+		}
+		if err := svc.notifiers[i].Notify(ctx, n); err != nil {
+			log.Warn().Err(err).Str("notifier", nc.Name).Str("event", n.Event).Msg("Error sending notification")
+			errs = append(errs, fmt.Sprintf("%v: %v", nc.Name, err))
+			continue
+		}
+		log.Debug().Str("notifier", nc.Name).Str("event", n.Event).Msg("Sent notification")
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// webhookNotifier covers everything that is an HTTP POST, with the payload
+// depending on the kind
+type webhookNotifier struct {
+	client *Client
+	kind   string
+	url    string
+	token  string
+}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n *Notification) error {
+	var body []byte
+	var err error
+	url := w.url
+	header := http.Header{}
+	switch w.kind {
+	case NotifierWebhook:
+		body, err = json.Marshal(n)
+		header.Set("Content-Type", "application/json")
+	case NotifierDiscord:
+		body, err = json.Marshal(map[string]string{"content": fmt.Sprintf("**%v**\n%v", n.Title, n.Text())})
+		header.Set("Content-Type", "application/json")
+	case NotifierSlack:
+		body, err = json.Marshal(map[string]string{"text": fmt.Sprintf("*%v*\n%v", n.Title, n.Text())})
+		header.Set("Content-Type", "application/json")
+	case NotifierNtfy:
+		body = []byte(n.Text())
+		header.Set("Title", n.Title)
+		header.Set("Tags", n.Event)
+		if w.token != "" {
+			header.Set("Authorization", "Bearer "+w.token)
+		}
+	case NotifierGotify:
+		url = strings.TrimSuffix(w.url, "/") + "/message"
+		body, err = json.Marshal(&gotifyMessage{Title: n.Title, Message: n.Text(), Priority: 5})
+		header.Set("Content-Type", "application/json")
+		header.Set("X-Gotify-Key", w.token)
+	}
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("User-Agent", w.client.UserAgent)
+	res, err := w.client.httpClient(ServiceNotify).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("%v returned %v: %v", w.kind, res.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+type smtpNotifier struct {
+	config NotifierConfig
+	send   func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (s *smtpNotifier) Notify(ctx context.Context, n *Notification) error {
+	port := s.config.Port
+	if port == 0 {
+		port = 587
+	}
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %v\r\n", s.config.From)
+	fmt.Fprintf(&msg, "To: %v\r\n", strings.Join(s.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %v\r\n", n.Title)
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Text(), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return s.send(ctx, fmt.Sprintf("%v:%v", s.config.Host, port), auth, s.config.From, s.config.To, msg.Bytes())
+}
+
+// smtpTimeout is the longest a whole email can take to send
+const smtpTimeout = 30 * time.Second
+
+// sendMail is smtp.SendMail, but connects with ctx and gives up on a server
+// that stops responding
+func sendMail(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	sc, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	if ok, _ := sc.Extension("STARTTLS"); ok {
+		if err = sc.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if a != nil {
+		if err = sc.Auth(a); err != nil {
+			return err
+		}
+	}
+	if err = sc.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = sc.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := sc.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return sc.Quit()
+}
@@ -0,0 +1,170 @@
+package letswatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNotifierErrors(t *testing.T) {
+	tests := map[string]struct {
+		config  NotifierConfig
+		wantErr string
+	}{
+		"unknown":  {config: NotifierConfig{Name: "x", Kind: "pager"}, wantErr: "notifier x has unknown kind: pager"},
+		"no-url":   {config: NotifierConfig{Name: "x", Kind: NotifierDiscord}, wantErr: "notifier x needs a url"},
+		"no-to":    {config: NotifierConfig{Name: "x", Kind: NotifierSMTP, Host: "mail", From: "me@example.com"}, wantErr: "notifier x needs a host, from and to"},
+		"good-url": {config: NotifierConfig{Name: "x", Kind: NotifierNtfy, URL: "https://ntfy.sh/movies"}},
+	}
+	for k, tt := range tests {
+		_, err := newNotifier(&Client{}, tt.config)
+		if tt.wantErr != "" {
+			require.EqualError(t, err, tt.wantErr, k)
+		} else {
+			require.NoError(t, err, k)
+		}
+	}
+}
+
+func TestNotifySend(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	bodies := map[string]string{}
+	headers := map[string]http.Header{}
+	record := func(name string) httpmock.Responder {
+		return func(req *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies[name] = string(b)
+			headers[name] = req.Header
+			return httpmock.NewStringResponse(200, "{}"), nil
+		}
+	}
+	httpmock.RegisterResponder("POST", "https://example.com/hook", record("webhook"))
+	httpmock.RegisterResponder("POST", "https://discord.example.com/api/webhooks/1", record("discord"))
+	httpmock.RegisterResponder("POST", "https://hooks.slack.example.com/services/1", record("slack"))
+	httpmock.RegisterResponder("POST", "https://ntfy.sh/movies", record("ntfy"))
+	httpmock.RegisterResponder("POST", "https://gotify.example.com/message", record("gotify"))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+		Notifiers: []NotifierConfig{
+			{Name: "webhook", Kind: NotifierWebhook, URL: "https://example.com/hook"},
+			{Name: "discord", Kind: NotifierDiscord, URL: "https://discord.example.com/api/webhooks/1"},
+			{Name: "slack", Kind: NotifierSlack, URL: "https://hooks.slack.example.com/services/1", Events: []string{EventRunFailed}},
+			{Name: "ntfy", Kind: NotifierNtfy, URL: "https://ntfy.sh/movies", Token: "tk_abc"},
+			{Name: "gotify", Kind: NotifierGotify, URL: "https://gotify.example.com/", Token: "app"},
+		},
+	})
+	require.NoError(t, err)
+
+	n := NewFilmsNotification(EventFilmsAdded, "Requested 1 films", []*letterboxd.Film{
+		{Title: "The Handmaiden", Year: 2016},
+	})
+	require.NoError(t, c.Notify.Send(context.Background(), n))
+
+	var got Notification
+	require.NoError(t, json.Unmarshal([]byte(bodies["webhook"]), &got))
+	require.Equal(t, *n, got)
+	require.JSONEq(t, `{"content":"**Requested 1 films**\n- The Handmaiden (2016)"}`, bodies["discord"])
+	require.Equal(t, "- The Handmaiden (2016)", bodies["ntfy"])
+	require.Equal(t, "Requested 1 films", headers["ntfy"].Get("Title"))
+	require.Equal(t, "Bearer tk_abc", headers["ntfy"].Get("Authorization"))
+	require.JSONEq(t, `{"title":"Requested 1 films","message":"- The Handmaiden (2016)","priority":5}`, bodies["gotify"])
+	require.Equal(t, "app", headers["gotify"].Get("X-Gotify-Key"))
+
+	// Slack only wants failures
+	_, ok := bodies["slack"]
+	require.False(t, ok)
+	require.NoError(t, c.Notify.Send(context.Background(), NewRunFailedNotification("supplement", errors.New("radarr is down"))))
+	require.JSONEq(t, `{"text":"*letswatch supplement failed*\nradarr is down"}`, bodies["slack"])
+}
+
+func TestNotifySendError(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("POST", "https://example.com/hook", httpmock.NewStringResponder(500, "nope"))
+	httpmock.RegisterResponder("POST", "https://ntfy.sh/movies", httpmock.NewStringResponder(200, "{}"))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+		Notifiers: []NotifierConfig{
+			{Name: "broken", Kind: NotifierWebhook, URL: "https://example.com/hook"},
+			{Name: "ntfy", Kind: NotifierNtfy, URL: "https://ntfy.sh/movies"},
+		},
+	})
+	require.NoError(t, err)
+	err = c.Notify.Send(context.Background(), &Notification{Event: EventRunFailed, Title: "oops"})
+	require.EqualError(t, err, "broken: webhook returned 500: nope")
+	// The failing notifier doesn't stop the others
+	require.Equal(t, 1, httpmock.GetCallCountInfo()["POST https://ntfy.sh/movies"])
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	s := &smtpNotifier{
+		config: NotifierConfig{
+			Name: "mail",
+			Kind: NotifierSMTP,
+			Host: "mail.example.com",
+			From: "letswatch@example.com",
+			To:   []string{"me@example.com"},
+		},
+		send: func(ctx context.Context, addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+			gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+			return nil
+		},
+	}
+	require.NoError(t, s.Notify(context.Background(), &Notification{
+		Event: EventNewRecommendations,
+		Title: "2 new recommendations",
+		Films: []string{"Audition (1999)", "Cure (1997)"},
+	}))
+	require.Equal(t, "mail.example.com:587", gotAddr)
+	require.Equal(t, "letswatch@example.com", gotFrom)
+	require.Equal(t, []string{"me@example.com"}, gotTo)
+	require.Contains(t, string(gotMsg), "Subject: 2 new recommendations\r\n")
+	require.Contains(t, string(gotMsg), "- Audition (1999)\r\n- Cure (1997)\r\n")
+}
+
+func TestSendMailTimeout(t *testing.T) {
+	// A server that accepts the connection but never says hello
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = sendMail(ctx, l.Addr().String(), nil, "letswatch@example.com", []string{"me@example.com"}, []byte("hi"))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,164 @@
+package letswatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// OverseerrService talks to Overseerr, or Jellyseerr, which shares the same API
+type OverseerrService interface {
+	MovieWithTMDBID(context.Context, int64) (*OverseerrMovie, error)
+	RequestMovie(context.Context, int64) (*OverseerrRequest, error)
+	Requester
+}
+
+type OverseerrServiceOp struct {
+	client  *Client
+	baseURL string
+	apiKey  string
+}
+
+// Media status values as returned by the Overseerr API
+const (
+	overseerrMediaUnknown            = 1
+	overseerrMediaPending            = 2
+	overseerrMediaProcessing         = 3
+	overseerrMediaPartiallyAvailable = 4
+	overseerrMediaAvailable          = 5
+)
+
+type OverseerrMovie struct {
+	ID        int64               `json:"id,omitempty"`
+	Title     string              `json:"title,omitempty"`
+	MediaInfo *OverseerrMediaInfo `json:"mediaInfo,omitempty"`
+}
+
+type OverseerrMediaInfo struct {
+	ID       int64               `json:"id,omitempty"`
+	TMDBID   int64               `json:"tmdbId,omitempty"`
+	Status   int                 `json:"status,omitempty"`
+	Requests []*OverseerrRequest `json:"requests,omitempty"`
+}
+
+type OverseerrRequest struct {
+	ID     int64 `json:"id,omitempty"`
+	Status int   `json:"status,omitempty"`
+}
+
+type overseerrRequestInput struct {
+	MediaType string `json:"mediaType"`
+	MediaID   int64  `json:"mediaId"`
+}
+
+func (svc *OverseerrServiceOp) do(ctx context.Context, method, path string, body interface{}, v interface{}) error {
+	if svc.baseURL == "" {
+		return errors.New("ErrNoOverseerrURL")
+	}
+	var payload []byte
+	var err error
+	if body != nil {
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	url := strings.TrimSuffix(svc.baseURL, "/") + "/api/v1" + path
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Api-Key", svc.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", svc.client.UserAgent)
+
+	res, err := svc.client.httpClient(ServiceOverseerr).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("overseerr returned %v: %s", res.StatusCode, data)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// MovieWithTMDBID returns the Overseerr view of a movie, including any
+// existing media info and requests
+func (svc *OverseerrServiceOp) MovieWithTMDBID(ctx context.Context, id int64) (*OverseerrMovie, error) {
+	var m OverseerrMovie
+	if err := svc.do(ctx, http.MethodGet, fmt.Sprintf("/movie/%v", id), nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RequestMovie submits a new request for the given TMDB ID
+func (svc *OverseerrServiceOp) RequestMovie(ctx context.Context, id int64) (*OverseerrRequest, error) {
+	var r OverseerrRequest
+	err := svc.do(ctx, http.MethodPost, "/request", &overseerrRequestInput{
+		MediaType: "movie",
+		MediaID:   id,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (svc *OverseerrServiceOp) RequestFilm(ctx context.Context, item *letterboxd.Film) error {
+	if item.ExternalIDs == nil {
+		return errors.New("ErrNoTMDBID")
+	}
+	tmdbID, err := strconv.ParseInt(item.ExternalIDs.TMDB, 10, 64)
+	if err != nil {
+		return err
+	}
+	r, err := svc.RequestMovie(ctx, tmdbID)
+	if err != nil {
+		return err
+	}
+	log.Debug().Int64("request", r.ID).Str("title", item.Title).Msg("Created overseerr request")
+	return nil
+}
+
+func (svc *OverseerrServiceOp) RequestStatus(ctx context.Context, tmdbID int64) (RequestStatus, error) {
+	m, err := svc.MovieWithTMDBID(ctx, tmdbID)
+	if err != nil {
+		return RequestStatusNone, err
+	}
+	if m.MediaInfo == nil {
+		return RequestStatusNone, nil
+	}
+	switch m.MediaInfo.Status {
+	case overseerrMediaPending:
+		return RequestStatusPending, nil
+	case overseerrMediaProcessing:
+		return RequestStatusProcessing, nil
+	case overseerrMediaPartiallyAvailable, overseerrMediaAvailable:
+		return RequestStatusAvailable, nil
+	}
+	if len(m.MediaInfo.Requests) > 0 {
+		return RequestStatusPending, nil
+	}
+	return RequestStatusNone, nil
+}
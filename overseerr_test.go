@@ -0,0 +1,83 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverseerrRequestStatus(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	movie, err := ioutil.ReadFile("testdata/overseerr_movie.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "https://overseerr.example.com/api/v1/movie/290098",
+		httpmock.NewStringResponder(200, string(movie)))
+	httpmock.RegisterResponder("GET", "https://overseerr.example.com/api/v1/movie/1",
+		httpmock.NewStringResponder(200, `{"id": 1, "title": "Unknown"}`))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:        "foo",
+		PlexURL:        "https://plex.example.com",
+		PlexToken:      "foo",
+		OverseerrURL:   "https://overseerr.example.com/",
+		OverseerrKey:   "foo",
+		RequestBackend: RequestBackendOverseerr,
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+
+	got, err := c.Requester.RequestStatus(context.Background(), 290098)
+	require.NoError(t, err)
+	require.Equal(t, RequestStatusProcessing, got)
+
+	got, err = c.Requester.RequestStatus(context.Background(), 1)
+	require.NoError(t, err)
+	require.False(t, got.IsRequested())
+}
+
+func TestOverseerrRequestFilm(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://overseerr.example.com/api/v1/request",
+		httpmock.NewStringResponder(201, `{"id": 8, "status": 1}`))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:        "foo",
+		PlexURL:        "https://plex.example.com",
+		PlexToken:      "foo",
+		OverseerrURL:   "https://overseerr.example.com",
+		RequestBackend: RequestBackendJellyseerr,
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+	err = c.Requester.RequestFilm(context.Background(), &letterboxd.Film{
+		Title:       "The Handmaiden",
+		ExternalIDs: &letterboxd.FilmExternalIDs{TMDB: "290098"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, httpmock.GetTotalCallCount())
+}
+
+func TestNewClientBadRequestBackend(t *testing.T) {
+	_, err := NewClient(ClientConfig{
+		TMDBKey:        "foo",
+		PlexURL:        "https://plex.example.com",
+		PlexToken:      "foo",
+		RequestBackend: "sonarr",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.EqualError(t, err, "unknown request backend: sonarr")
+}
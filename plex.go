@@ -2,13 +2,21 @@ package letswatch
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
 
 	"github.com/jrudio/go-plex-client"
 )
 
 type PlexService interface {
 	// GetWithIMDBID(context.Context, string) (*tmdb.MovieDetails, error)
-	IsAvailable(context.Context, string, int) (bool, error)
+	MediaServer
+	FindMovie(context.Context, string, int) (*PlexMovie, error)
+	WatchedIMDBIDs(context.Context, []string) ([]string, error)
+	UpdateCollection(context.Context, string, []*PlexMovie, PlexListMode) error
+	UpdatePlaylist(context.Context, string, []*PlexMovie, PlexListMode) error
 }
 
 type PlexServiceOp struct {
@@ -16,10 +24,24 @@ type PlexServiceOp struct {
 	plexClient *plex.Plex
 }
 
-func (p *PlexServiceOp) IsAvailable(ctx context.Context, title string, year int) (bool, error) {
+func (p *PlexServiceOp) Name() string {
+	return MediaServerPlex
+}
+
+// PlexMovie is a movie in one of the Plex libraries
+type PlexMovie struct {
+	RatingKey string
+	SectionID string
+	Title     string
+	Year      int
+}
+
+// FindMovie returns the library item matching the title, give or take a couple
+// years on the release date. nil is returned if there is no match
+func (p *PlexServiceOp) FindMovie(ctx context.Context, title string, year int) (*PlexMovie, error) {
 	res, err := p.plexClient.Search(title)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	padding := 2
 	earliest := year - padding
@@ -27,8 +49,166 @@ func (p *PlexServiceOp) IsAvailable(ctx context.Context, title string, year int)
 	// fmt.Fprintf(os.Stderr, "%+v\n", res.MediaContainer.Metadata)
 	for _, d := range res.MediaContainer.Metadata {
 		if d.Title == title && inBetween(d.Year, earliest, latest) {
-			return true, nil
+			return &PlexMovie{
+				RatingKey: d.RatingKey,
+				SectionID: d.LibrarySectionID.String(),
+				Title:     d.Title,
+				Year:      d.Year,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *PlexServiceOp) IsAvailable(ctx context.Context, title string, year int) (bool, error) {
+	m, err := p.FindMovie(ctx, title, year)
+	if err != nil {
+		return false, err
+	}
+	return m != nil, nil
+}
+
+type plexAccounts struct {
+	MediaContainer struct {
+		Account []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"Account"`
+	} `json:"MediaContainer"`
+}
+
+type plexHistory struct {
+	MediaContainer struct {
+		Metadata []struct {
+			RatingKey string `json:"ratingKey"`
+			Title     string `json:"title"`
+			Type      string `json:"type"`
+			AccountID int    `json:"accountID"`
+		} `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+type plexMetadata struct {
+	MediaContainer struct {
+		Metadata []struct {
+			RatingKey string `json:"ratingKey"`
+			Title     string `json:"title"`
+			Year      int    `json:"year"`
+			Guid      []struct {
+				ID string `json:"id"`
+			} `json:"Guid"`
+		} `json:"Metadata"`
+	} `json:"MediaContainer"`
+}
+
+// do sends a request to the Plex server and decodes any JSON response in to v
+func (p *PlexServiceOp) do(ctx context.Context, method, path string, v interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	u := strings.TrimSuffix(p.plexClient.URL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Plex-Token", p.plexClient.Token)
+	res, err := p.client.httpClient(ServicePlex).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("plex returned %v for %v", res.StatusCode, path)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (p *PlexServiceOp) get(ctx context.Context, path string, v interface{}) error {
+	return p.do(ctx, http.MethodGet, path, v)
+}
+
+// accountIDs returns the Plex account IDs for the given account names,
+// including managed users. If no names are given, all accounts are returned
+func (p *PlexServiceOp) accountIDs(ctx context.Context, names []string) ([]int, error) {
+	var accounts plexAccounts
+	if err := p.get(ctx, "/accounts", &accounts); err != nil {
+		return nil, err
+	}
+	ret := []int{}
+	for _, a := range accounts.MediaContainer.Account {
+		if len(names) == 0 || ContainsString(names, a.Name) {
+			ret = append(ret, a.ID)
+		}
+	}
+	if len(names) > 0 && len(ret) == 0 {
+		return nil, fmt.Errorf("no plex accounts found matching: %v", names)
+	}
+	return ret, nil
+}
+
+// WatchedIMDBIDs returns the IMDB IDs of the movies in the play history of the
+// given Plex accounts. An empty list of accounts means everyone on the server
+func (p *PlexServiceOp) WatchedIMDBIDs(ctx context.Context, accounts []string) ([]string, error) {
+	ids, err := p.accountIDs(ctx, accounts)
+	if err != nil {
+		return nil, err
+	}
+	ratingKeys := []string{}
+	for _, id := range ids {
+		var history plexHistory
+		if err := p.get(ctx, fmt.Sprintf("/status/sessions/history/all?type=1&accountID=%v", id), &history); err != nil {
+			return nil, err
+		}
+		for _, h := range history.MediaContainer.Metadata {
+			if h.Type == "movie" && h.RatingKey != "" {
+				ratingKeys = append(ratingKeys, h.RatingKey)
+			}
+		}
+	}
+
+	if len(ratingKeys) == 0 {
+		return []string{}, nil
+	}
+
+	imdbIDs, err := p.libraryIMDBIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, key := range removeDups(ratingKeys) {
+		// Items removed from the library stay in the history, and are skipped
+		ret = append(ret, imdbIDs[key]...)
+	}
+	return removeDups(ret), nil
+}
+
+// libraryIMDBIDs returns the IMDB IDs of every movie in the movie libraries by
+// rating key, fetched a library at a time rather than an item at a time
+func (p *PlexServiceOp) libraryIMDBIDs(ctx context.Context) (map[string][]string, error) {
+	var sections plexContainer
+	if err := p.get(ctx, "/library/sections", &sections); err != nil {
+		return nil, err
+	}
+	ret := map[string][]string{}
+	for _, section := range sections.MediaContainer.Directory {
+		if section.Type != "movie" {
+			continue
+		}
+		var md plexMetadata
+		if err := p.get(ctx, fmt.Sprintf("/library/sections/%v/all?includeGuids=1", section.Key), &md); err != nil {
+			return nil, err
+		}
+		for _, m := range md.MediaContainer.Metadata {
+			for _, g := range m.Guid {
+				if strings.HasPrefix(g.ID, "imdb://") {
+					ret[m.RatingKey] = append(ret[m.RatingKey], strings.TrimPrefix(g.ID, "imdb://"))
+				}
+			}
 		}
 	}
-	return false, nil
+	return ret, nil
 }
@@ -0,0 +1,230 @@
+package letswatch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// PlexListMode says what to do with items already in a collection or playlist
+type PlexListMode string
+
+const (
+	// PlexListReplace makes the list contain exactly the new items
+	PlexListReplace PlexListMode = "replace"
+	// PlexListAppend adds the new items, keeping what is already there
+	PlexListAppend PlexListMode = "append"
+)
+
+// ParsePlexListMode returns the PlexListMode for a string such as 'append'
+func ParsePlexListMode(s string) (PlexListMode, error) {
+	switch PlexListMode(strings.ToLower(s)) {
+	case PlexListReplace:
+		return PlexListReplace, nil
+	case PlexListAppend:
+		return PlexListAppend, nil
+	default:
+		return "", fmt.Errorf("unknown plex list mode: %v", s)
+	}
+}
+
+type plexContainer struct {
+	MediaContainer struct {
+		MachineIdentifier string `json:"machineIdentifier"`
+		Metadata          []struct {
+			RatingKey string `json:"ratingKey"`
+			Title     string `json:"title"`
+		} `json:"Metadata"`
+		Directory []struct {
+			Key   string `json:"key"`
+			Type  string `json:"type"`
+			Title string `json:"title"`
+		} `json:"Directory"`
+	} `json:"MediaContainer"`
+}
+
+// itemsURI is the server uri that Plex uses to refer to a set of library items
+func (p *PlexServiceOp) itemsURI(ctx context.Context, keys []string) (string, error) {
+	var identity plexContainer
+	if err := p.get(ctx, "/identity", &identity); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("server://%v/com.plexapp.plugins.library/library/metadata/%v",
+		identity.MediaContainer.MachineIdentifier, strings.Join(keys, ",")), nil
+}
+
+// childKeys returns the rating keys of everything under the given path
+func (p *PlexServiceOp) childKeys(ctx context.Context, path string) ([]string, error) {
+	var children plexContainer
+	if err := p.get(ctx, path, &children); err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, c := range children.MediaContainer.Metadata {
+		ret = append(ret, c.RatingKey)
+	}
+	return ret, nil
+}
+
+// keyWithTitle returns the rating key of the item under path with the given
+// title, or an empty string if there isn't one
+func (p *PlexServiceOp) keyWithTitle(ctx context.Context, path, title string) (string, error) {
+	var container plexContainer
+	if err := p.get(ctx, path, &container); err != nil {
+		return "", err
+	}
+	for _, m := range container.MediaContainer.Metadata {
+		if m.Title == title {
+			return m.RatingKey, nil
+		}
+	}
+	return "", nil
+}
+
+// missingKeys returns the keys in want that are not in have
+func missingKeys(want, have []string) []string {
+	ret := []string{}
+	for _, k := range want {
+		if !ContainsString(have, k) {
+			ret = append(ret, k)
+		}
+	}
+	return ret
+}
+
+func ratingKeys(items []*PlexMovie) []string {
+	ret := []string{}
+	for _, i := range items {
+		ret = append(ret, i.RatingKey)
+	}
+	return removeDups(ret)
+}
+
+// UpdateCollection creates or updates a collection with the given name in
+// every movie library. Collections can't span libraries, so items are grouped
+// by the library they came from
+func (p *PlexServiceOp) UpdateCollection(ctx context.Context, name string, items []*PlexMovie, mode PlexListMode) error {
+	bySection := map[string][]*PlexMovie{}
+	for _, i := range items {
+		bySection[i.SectionID] = append(bySection[i.SectionID], i)
+	}
+
+	var sections plexContainer
+	if err := p.get(ctx, "/library/sections", &sections); err != nil {
+		return err
+	}
+	for _, section := range sections.MediaContainer.Directory {
+		if section.Type != "movie" {
+			continue
+		}
+		want := ratingKeys(bySection[section.Key])
+		collectionKey, err := p.keyWithTitle(ctx, fmt.Sprintf("/library/sections/%v/collections", section.Key), name)
+		if err != nil {
+			return err
+		}
+
+		// Brand new collection
+		if collectionKey == "" {
+			if len(want) == 0 {
+				continue
+			}
+			uri, err := p.itemsURI(ctx, want)
+			if err != nil {
+				return err
+			}
+			q := url.Values{}
+			q.Set("type", "1")
+			q.Set("title", name)
+			q.Set("smart", "0")
+			q.Set("sectionId", section.Key)
+			q.Set("uri", uri)
+			log.Info().Str("collection", name).Str("library", section.Title).Int("items", len(want)).Msg("Creating plex collection")
+			if err := p.do(ctx, http.MethodPost, "/library/collections?"+q.Encode(), nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		have, err := p.childKeys(ctx, fmt.Sprintf("/library/collections/%v/children", collectionKey))
+		if err != nil {
+			return err
+		}
+		if mode == PlexListReplace {
+			for _, k := range missingKeys(have, want) {
+				if err := p.do(ctx, http.MethodDelete, fmt.Sprintf("/library/collections/%v/children/%v", collectionKey, k), nil); err != nil {
+					return err
+				}
+			}
+		}
+		add := missingKeys(want, have)
+		log.Info().Str("collection", name).Str("library", section.Title).Int("added", len(add)).Str("mode", string(mode)).Msg("Updating plex collection")
+		if len(add) == 0 {
+			continue
+		}
+		uri, err := p.itemsURI(ctx, add)
+		if err != nil {
+			return err
+		}
+		q := url.Values{}
+		q.Set("uri", uri)
+		if err := p.do(ctx, http.MethodPut, fmt.Sprintf("/library/collections/%v/items?%v", collectionKey, q.Encode()), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdatePlaylist creates or updates a video playlist with the given name
+func (p *PlexServiceOp) UpdatePlaylist(ctx context.Context, name string, items []*PlexMovie, mode PlexListMode) error {
+	want := ratingKeys(items)
+	playlistKey, err := p.keyWithTitle(ctx, "/playlists?playlistType=video", name)
+	if err != nil {
+		return err
+	}
+
+	// Brand new playlist
+	if playlistKey == "" {
+		if len(want) == 0 {
+			return nil
+		}
+		uri, err := p.itemsURI(ctx, want)
+		if err != nil {
+			return err
+		}
+		q := url.Values{}
+		q.Set("type", "video")
+		q.Set("title", name)
+		q.Set("smart", "0")
+		q.Set("uri", uri)
+		log.Info().Str("playlist", name).Int("items", len(want)).Msg("Creating plex playlist")
+		return p.do(ctx, http.MethodPost, "/playlists?"+q.Encode(), nil)
+	}
+
+	add := want
+	if mode == PlexListReplace {
+		if err := p.do(ctx, http.MethodDelete, fmt.Sprintf("/playlists/%v/items", playlistKey), nil); err != nil {
+			return err
+		}
+	} else {
+		have, err := p.childKeys(ctx, fmt.Sprintf("/playlists/%v/items", playlistKey))
+		if err != nil {
+			return err
+		}
+		add = missingKeys(want, have)
+	}
+	log.Info().Str("playlist", name).Int("added", len(add)).Str("mode", string(mode)).Msg("Updating plex playlist")
+	if len(add) == 0 {
+		return nil
+	}
+	uri, err := p.itemsURI(ctx, add)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("uri", uri)
+	return p.do(ctx, http.MethodPut, fmt.Sprintf("/playlists/%v/items?%v", playlistKey, q.Encode()), nil)
+}
@@ -0,0 +1,57 @@
+package letswatch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlexListMode(t *testing.T) {
+	got, err := ParsePlexListMode("Append")
+	require.NoError(t, err)
+	require.Equal(t, PlexListAppend, got)
+
+	_, err = ParsePlexListMode("merge")
+	require.EqualError(t, err, "unknown plex list mode: merge")
+}
+
+func TestUpdatePlaylist(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://plex.example.com/identity",
+		httpmock.NewStringResponder(200, `{"MediaContainer":{"machineIdentifier":"abc123"}}`))
+	httpmock.RegisterResponder("GET", "https://plex.example.com/playlists?playlistType=video",
+		httpmock.NewStringResponder(200, `{"MediaContainer":{"Metadata":[{"ratingKey":"900","title":"Letswatch Picks"}]}}`))
+	httpmock.RegisterResponder("GET", "https://plex.example.com/playlists/900/items",
+		httpmock.NewStringResponder(200, `{"MediaContainer":{"Metadata":[{"ratingKey":"1","title":"Old"}]}}`))
+	httpmock.RegisterResponder("PUT", "=~^https://plex.example.com/playlists/900/items",
+		func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "server://abc123/com.plexapp.plugins.library/library/metadata/2", req.URL.Query().Get("uri"))
+			return httpmock.NewStringResponse(200, ""), nil
+		})
+	httpmock.RegisterResponder("POST", "=~^https://plex.example.com/playlists",
+		httpmock.NewStringResponder(200, ""))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:   "foo",
+		PlexURL:   "https://plex.example.com",
+		PlexToken: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+
+	items := []*PlexMovie{{RatingKey: "1"}, {RatingKey: "2"}}
+	require.NoError(t, c.Plex.UpdatePlaylist(context.Background(), "Letswatch Picks", items, PlexListAppend))
+	require.NoError(t, c.Plex.UpdatePlaylist(context.Background(), "Brand New", items, PlexListReplace))
+
+	calls := httpmock.GetCallCountInfo()
+	require.Equal(t, 1, calls["PUT =~^https://plex.example.com/playlists/900/items"])
+	require.Equal(t, 1, calls["POST =~^https://plex.example.com/playlists"])
+}
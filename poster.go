@@ -0,0 +1,107 @@
+package letswatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // TMDB posters are JPEGs
+	_ "image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Where TMDB poster images are served from, and the size we fetch. w185 is
+// plenty for a terminal
+const (
+	tmdbImageBaseURL = "https://image.tmdb.org/t/p"
+	tmdbPosterSize   = "w185"
+)
+
+// PosterService fetches film posters
+type PosterService interface {
+	// Get returns the poster at the TMDB poster path, like '/abc123.jpg'
+	Get(ctx context.Context, posterPath string) (image.Image, error)
+}
+
+// PosterServiceOp fetches posters from TMDB, keeping a copy of each on disk so
+// they are only ever downloaded once
+type PosterServiceOp struct {
+	client   *Client
+	baseURL  string
+	cacheDir string
+}
+
+// posterCachePath is where the poster lives on disk. TMDB paths are unique
+// file names, so they are used as is
+func (p *PosterServiceOp) posterCachePath(posterPath string) string {
+	return filepath.Join(p.cacheDir, tmdbPosterSize, filepath.Base(posterPath))
+}
+
+func (p *PosterServiceOp) Get(ctx context.Context, posterPath string) (image.Image, error) {
+	if posterPath == "" || strings.Contains(posterPath, "..") {
+		return nil, fmt.Errorf("invalid poster path: %q", posterPath)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var data []byte
+	var err error
+	if p.cacheDir != "" {
+		data, err = ioutil.ReadFile(p.posterCachePath(posterPath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if data == nil {
+		if data, err = p.fetch(ctx, posterPath); err != nil {
+			return nil, err
+		}
+		if p.cacheDir != "" {
+			if err = p.save(posterPath, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding poster %v: %w", posterPath, err)
+	}
+	return img, nil
+}
+
+func (p *PosterServiceOp) fetch(ctx context.Context, posterPath string) ([]byte, error) {
+	u := fmt.Sprintf("%v/%v/%v", strings.TrimSuffix(p.baseURL, "/"), tmdbPosterSize, strings.TrimPrefix(posterPath, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.client.UserAgent)
+	res, err := p.client.httpClient(ServicePosters).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching poster %v returned %v", posterPath, res.StatusCode)
+	}
+	return data, nil
+}
+
+// save writes the poster to a temp file first, so a half written poster is
+// never read back
+func (p *PosterServiceOp) save(posterPath string, data []byte) error {
+	dest := p.posterCachePath(posterPath)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(dest, data)
+}
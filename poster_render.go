@@ -0,0 +1,307 @@
+package letswatch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync/atomic"
+)
+
+// Ways of drawing a poster in the terminal
+const (
+	PosterAuto      = "auto"
+	PosterKitty     = "kitty"
+	PosterITerm2    = "iterm2"
+	PosterSixel     = "sixel"
+	PosterHalfBlock = "halfblock"
+	PosterNone      = "none"
+)
+
+// Terminal cells are roughly twice as tall as they are wide. Sixel works in
+// pixels, so assume a common cell size
+const (
+	posterCellWidth  = 10
+	posterCellHeight = 20
+)
+
+// kitty only takes so much base64 in a single escape
+const kittyChunkSize = 4096
+
+// DetectPosterProtocol guesses the best protocol the terminal supports from
+// its environment. Anything unknown gets half blocks, which only need 24 bit
+// colour
+func DetectPosterProtocol(getenv func(string) string) string {
+	term := getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		return PosterNone
+	// Graphics need passing through tmux specially, so play it safe
+	case getenv("TMUX") != "":
+		return PosterHalfBlock
+	case getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || getenv("TERM_PROGRAM") == "ghostty":
+		return PosterKitty
+	case getenv("TERM_PROGRAM") == "iTerm.app" || getenv("TERM_PROGRAM") == "WezTerm":
+		return PosterITerm2
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm"):
+		return PosterSixel
+	default:
+		return PosterHalfBlock
+	}
+}
+
+// ValidPosterProtocol returns an error if p isn't a protocol we know
+func ValidPosterProtocol(p string) error {
+	switch p {
+	case PosterAuto, PosterKitty, PosterITerm2, PosterSixel, PosterHalfBlock, PosterNone:
+		return nil
+	default:
+		return fmt.Errorf("unknown poster protocol: %v", p)
+	}
+}
+
+// Poster is an image ready to write to the terminal
+type Poster struct {
+	Protocol string
+	// Size in terminal cells
+	Cols int
+	Rows int
+	// Data is lines of coloured half blocks for PosterHalfBlock, which can be
+	// laid out like any other text. For the graphics protocols it is a single
+	// escape sequence to write at the cursor
+	Data string
+	// Place shows a kitty poster at the cursor once Data has sent it, without
+	// sending the image again
+	Place string
+}
+
+// posterFit returns the largest size, in cells, that fits the image within
+// cols by rows without stretching it
+func posterFit(img image.Image, cols, rows int) (int, int) {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0, 0
+	}
+	// Work in half cells, which are about square
+	w, h := cols, rows*2
+	if b.Dx()*h > b.Dy()*w {
+		h = w * b.Dy() / b.Dx()
+	} else {
+		w = h * b.Dx() / b.Dy()
+	}
+	if w < 1 {
+		w = 1
+	}
+	return w, (h + 1) / 2
+}
+
+// RenderPoster draws the image to fit within cols by rows cells
+func RenderPoster(img image.Image, protocol string, cols, rows int) (*Poster, error) {
+	c, r := posterFit(img, cols, rows)
+	if c == 0 || r == 0 {
+		return nil, fmt.Errorf("poster has no size")
+	}
+	p := &Poster{Protocol: protocol, Cols: c, Rows: r}
+	var err error
+	switch protocol {
+	case PosterHalfBlock:
+		p.Data = renderHalfBlock(scaleImage(img, c, r*2))
+	case PosterKitty:
+		id := atomic.AddUint32(&kittyImageID, 1)
+		p.Data, err = renderKitty(img, id)
+		p.Place = kittyPlace(id, c, r)
+	case PosterITerm2:
+		p.Data, err = renderITerm2(img, c, r)
+	case PosterSixel:
+		p.Data = renderSixel(scaleImage(img, c*posterCellWidth, r*posterCellHeight))
+	default:
+		return nil, fmt.Errorf("unknown poster protocol: %v", protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// scaleImage resizes the image by averaging the source pixels behind each new
+// one. Posters are only ever shrunk, so this looks fine without anything
+// fancier
+func scaleImage(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	ret := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+pr, g+pg, bl+pb, a+pa
+					n++
+				}
+			}
+			ret.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return ret
+}
+
+// renderHalfBlock draws two pixels per cell, using the upper half block in the
+// top pixel's colour over the bottom pixel's colour
+func renderHalfBlock(img *image.RGBA) string {
+	b := img.Bounds()
+	lines := []string{}
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		var line strings.Builder
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top := img.RGBAAt(x, y)
+			bottom := top
+			if y+1 < b.Max.Y {
+				bottom = img.RGBAAt(x, y+1)
+			}
+			fmt.Fprintf(&line, "\x1b[38;2;%v;%v;%vm\x1b[48;2;%v;%v;%vm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		line.WriteString("\x1b[0m")
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func encodePNGBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// kittyImageID is the last ID given to an image sent to kitty
+var kittyImageID uint32
+
+// renderKitty sends the image to kitty as a PNG with the ID, without showing
+// it. The terminal is asked not to reply, as replies would turn up as key
+// presses
+func renderKitty(img image.Image, id uint32) (string, error) {
+	data, err := encodePNGBase64(img)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := 0; i < len(data); i += kittyChunkSize {
+		end := i + kittyChunkSize
+		more := 1
+		if end >= len(data) {
+			end = len(data)
+			more = 0
+		}
+		if i == 0 {
+			fmt.Fprintf(&b, "\x1b_Ga=t,f=100,i=%v,q=2,m=%v;%v\x1b\\", id, more, data[i:end])
+		} else {
+			fmt.Fprintf(&b, "\x1b_Gm=%v;%v\x1b\\", more, data[i:end])
+		}
+	}
+	return b.String(), nil
+}
+
+// kittyPlace shows an image already sent to kitty at the cursor, scaled to
+// cols by rows. The cursor is left where it was
+func kittyPlace(id uint32, cols, rows int) string {
+	return fmt.Sprintf("\x1b_Ga=p,i=%v,q=2,C=1,c=%v,r=%v\x1b\\", id, cols, rows)
+}
+
+// kittyClear removes every image kitty is showing. The images are kept, to be
+// placed again
+const kittyClear = "\x1b_Ga=d,q=2\x1b\\"
+
+// renderITerm2 sends the image as an inline file, scaled by the terminal to
+// cols by rows
+func renderITerm2(img image.Image, cols, rows int) (string, error) {
+	data, err := encodePNGBase64(img)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("\x1b]1337;File=inline=1;width=%v;height=%v;preserveAspectRatio=1:%v\a", cols, rows, data), nil
+}
+
+// sixelColor maps a colour on to a 6x6x6 cube, which is plenty for a poster
+// this small and keeps the palette within what every sixel terminal supports
+func sixelColor(c color.RGBA) int {
+	return int(c.R)*6/256*36 + int(c.G)*6/256*6 + int(c.B)*6/256
+}
+
+// renderSixel encodes the image as sixels. Each band of six pixel rows is
+// drawn once per colour in it, with runs of the same sixel compressed
+func renderSixel(img *image.RGBA) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var out strings.Builder
+	fmt.Fprintf(&out, "\x1bPq\"1;1;%v;%v", w, h)
+	for i := 0; i < 216; i++ {
+		fmt.Fprintf(&out, "#%v;2;%v;%v;%v", i, i/36*100/5, i/6%6*100/5, i%6*100/5)
+	}
+	bits := make([]byte, w)
+	for band := 0; band < h; band += 6 {
+		used := map[int]bool{}
+		order := []int{}
+		for y := band; y < band+6 && y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := sixelColor(img.RGBAAt(b.Min.X+x, b.Min.Y+y))
+				if !used[c] {
+					used[c] = true
+					order = append(order, c)
+				}
+			}
+		}
+		for n, c := range order {
+			for x := 0; x < w; x++ {
+				bits[x] = 0
+				for i := 0; i < 6 && band+i < h; i++ {
+					if sixelColor(img.RGBAAt(b.Min.X+x, b.Min.Y+band+i)) == c {
+						bits[x] |= 1 << i
+					}
+				}
+			}
+			fmt.Fprintf(&out, "#%v", c)
+			writeSixelRuns(&out, bits)
+			if n < len(order)-1 {
+				out.WriteString("$")
+			}
+		}
+		out.WriteString("-")
+	}
+	out.WriteString("\x1b\\")
+	return out.String()
+}
+
+func writeSixelRuns(out *strings.Builder, bits []byte) {
+	for i := 0; i < len(bits); {
+		j := i
+		for j < len(bits) && bits[j] == bits[i] {
+			j++
+		}
+		ch := rune('?' + bits[i])
+		if run := j - i; run > 3 {
+			fmt.Fprintf(out, "!%v%c", run, ch)
+		} else {
+			out.WriteString(strings.Repeat(string(ch), run))
+		}
+		i = j
+	}
+}
@@ -0,0 +1,264 @@
+package letswatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoadPoster(t *testing.T, name string) image.Image {
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	require.NoError(t, err)
+	return img
+}
+
+func TestDetectPosterProtocol(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"no term":  {env: map[string]string{}, want: PosterNone},
+		"dumb":     {env: map[string]string{"TERM": "dumb"}, want: PosterNone},
+		"kitty":    {env: map[string]string{"TERM": "xterm-kitty"}, want: PosterKitty},
+		"kitty id": {env: map[string]string{"TERM": "xterm-256color", "KITTY_WINDOW_ID": "1"}, want: PosterKitty},
+		"iterm2":   {env: map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, want: PosterITerm2},
+		"foot":     {env: map[string]string{"TERM": "foot"}, want: PosterSixel},
+		"tmux":     {env: map[string]string{"TERM": "xterm-kitty", "TMUX": "/tmp/tmux"}, want: PosterHalfBlock},
+		"xterm":    {env: map[string]string{"TERM": "xterm-256color"}, want: PosterHalfBlock},
+	}
+	for name, tt := range tests {
+		got := DetectPosterProtocol(func(k string) string { return tt.env[k] })
+		require.Equal(t, tt.want, got, name)
+	}
+}
+
+func TestRenderPosterHalfBlock(t *testing.T) {
+	// The fixture is 4x6, red over blue on the left and green on the right
+	p, err := RenderPoster(mustLoadPoster(t, "poster.png"), PosterHalfBlock, 4, 3)
+	require.NoError(t, err)
+	require.Equal(t, 4, p.Cols)
+	require.Equal(t, 3, p.Rows)
+
+	cell := func(top, bottom string) string {
+		return fmt.Sprintf("\x1b[38;2;%vm\x1b[48;2;%vm▀", top, bottom)
+	}
+	red, green, blue := "255;0;0", "0;255;0", "0;0;255"
+	require.Equal(t, strings.Join([]string{
+		cell(red, red) + cell(red, red) + cell(green, green) + cell(green, green) + "\x1b[0m",
+		cell(red, blue) + cell(red, blue) + cell(green, green) + cell(green, green) + "\x1b[0m",
+		cell(blue, blue) + cell(blue, blue) + cell(green, green) + cell(green, green) + "\x1b[0m",
+	}, "\n"), p.Data)
+
+	// Scaled down to fit, keeping the shape
+	p, err = RenderPoster(mustLoadPoster(t, "poster.jpg"), PosterHalfBlock, 16, 12)
+	require.NoError(t, err)
+	require.Equal(t, 16, p.Cols)
+	require.Equal(t, 12, p.Rows)
+	p, err = RenderPoster(mustLoadPoster(t, "poster.jpg"), PosterHalfBlock, 16, 6)
+	require.NoError(t, err)
+	require.Equal(t, 8, p.Cols)
+	require.Equal(t, 6, p.Rows)
+}
+
+// decodeInlinePNG pulls the PNG back out of a graphics escape
+func decodeInlinePNG(t *testing.T, data string) image.Image {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(raw))
+	require.NoError(t, err)
+	return img
+}
+
+func TestRenderPosterKitty(t *testing.T) {
+	p, err := RenderPoster(mustLoadPoster(t, "poster.png"), PosterKitty, 4, 3)
+	require.NoError(t, err)
+	// Sent once with an ID, then placed by it
+	prefix := fmt.Sprintf("\x1b_Ga=t,f=100,i=%v,q=2,m=0;", kittyImageID)
+	require.True(t, strings.HasPrefix(p.Data, prefix))
+	require.Equal(t, fmt.Sprintf("\x1b_Ga=p,i=%v,q=2,C=1,c=4,r=3\x1b\\", kittyImageID), p.Place)
+	require.True(t, strings.HasSuffix(p.Data, "\x1b\\"))
+	img := decodeInlinePNG(t, strings.TrimSuffix(strings.TrimPrefix(p.Data, prefix), "\x1b\\"))
+	require.Equal(t, image.Rect(0, 0, 4, 6), img.Bounds())
+
+	// Big images are sent in chunks
+	noise := image.NewRGBA(image.Rect(0, 0, 64, 96))
+	r := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(r.Intn(256))
+	}
+	p, err = RenderPoster(noise, PosterKitty, 16, 12)
+	require.NoError(t, err)
+	chunks := strings.Split(strings.TrimSuffix(p.Data, "\x1b\\"), "\x1b\\")
+	require.Greater(t, len(chunks), 1)
+	var data strings.Builder
+	for i, chunk := range chunks {
+		parts := strings.SplitN(chunk, ";", 2)
+		require.LessOrEqual(t, len(parts[1]), kittyChunkSize)
+		switch {
+		case i == 0:
+			require.Equal(t, fmt.Sprintf("\x1b_Ga=t,f=100,i=%v,q=2,m=1", kittyImageID), parts[0])
+		case i == len(chunks)-1:
+			require.Equal(t, "\x1b_Gm=0", parts[0])
+		default:
+			require.Equal(t, "\x1b_Gm=1", parts[0])
+		}
+		data.WriteString(parts[1])
+	}
+	require.Equal(t, noise.Bounds(), decodeInlinePNG(t, data.String()).Bounds())
+}
+
+func TestRenderPosterITerm2(t *testing.T) {
+	p, err := RenderPoster(mustLoadPoster(t, "poster.png"), PosterITerm2, 4, 3)
+	require.NoError(t, err)
+	prefix := "\x1b]1337;File=inline=1;width=4;height=3;preserveAspectRatio=1:"
+	require.True(t, strings.HasPrefix(p.Data, prefix))
+	require.True(t, strings.HasSuffix(p.Data, "\a"))
+	img := decodeInlinePNG(t, strings.TrimSuffix(strings.TrimPrefix(p.Data, prefix), "\a"))
+	require.Equal(t, color.RGBA{0, 255, 0, 255}, color.RGBAModel.Convert(img.At(3, 0)))
+}
+
+func TestRenderPosterSixel(t *testing.T) {
+	p, err := RenderPoster(mustLoadPoster(t, "poster.png"), PosterSixel, 4, 3)
+	require.NoError(t, err)
+	// 4x3 cells is 40x60 pixels, which is ten bands of six
+	require.True(t, strings.HasPrefix(p.Data, "\x1bPq\"1;1;40;60#0;2;0;0;0#1;2;0;0;20"))
+	require.True(t, strings.HasSuffix(p.Data, "\x1b\\"))
+	body := p.Data[strings.Index(p.Data, "#215;2;100;100;100")+len("#215;2;100;100;100") : len(p.Data)-2]
+	bands := strings.Split(strings.TrimSuffix(body, "-"), "-")
+	require.Len(t, bands, 10)
+	// Red on the left, green on the right
+	require.Equal(t, "#180!20~!20?$#30!20?!20~", bands[0])
+	// Blue takes over half way down
+	require.Equal(t, "#5!20~!20?$#30!20?!20~", bands[9])
+}
+
+func TestRenderPosterErrors(t *testing.T) {
+	_, err := RenderPoster(mustLoadPoster(t, "poster.png"), "ascii-art", 4, 3)
+	require.EqualError(t, err, "unknown poster protocol: ascii-art")
+	_, err = RenderPoster(image.NewRGBA(image.Rect(0, 0, 0, 0)), PosterHalfBlock, 4, 3)
+	require.Error(t, err)
+	require.NoError(t, ValidPosterProtocol(PosterAuto))
+	require.Error(t, ValidPosterProtocol("ascii-art"))
+}
+
+func TestPosterService(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	jpg, err := ioutil.ReadFile("testdata/poster.jpg")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "https://image.tmdb.org/t/p/w185/abc123.jpg",
+		httpmock.NewBytesResponder(200, jpg))
+	httpmock.RegisterResponder("GET", "https://image.tmdb.org/t/p/w185/missing.jpg",
+		httpmock.NewStringResponder(404, "not found"))
+
+	dir := t.TempDir()
+	p := &PosterServiceOp{
+		client:   &Client{HTTPClient: http.DefaultClient, UserAgent: "letswatch"},
+		baseURL:  tmdbImageBaseURL,
+		cacheDir: dir,
+	}
+	for i := 0; i < 2; i++ {
+		img, err := p.Get(context.Background(), "/abc123.jpg")
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 40, 60), img.Bounds())
+	}
+	// The second one came from disk
+	require.Equal(t, 1, httpmock.GetTotalCallCount())
+	require.FileExists(t, filepath.Join(dir, "w185", "abc123.jpg"))
+
+	_, err = p.Get(context.Background(), "/missing.jpg")
+	require.EqualError(t, err, "fetching poster /missing.jpg returned 404")
+	require.NoFileExists(t, filepath.Join(dir, "w185", "missing.jpg"))
+	_, err = p.Get(context.Background(), "/../../etc/passwd")
+	require.Error(t, err)
+}
+
+type fakePosterService struct {
+	img image.Image
+}
+
+func (f *fakePosterService) Get(ctx context.Context, posterPath string) (image.Image, error) {
+	return f.img, nil
+}
+
+func TestUIPosters(t *testing.T) {
+	movies := []*Movie{{Title: "The Handmaiden", ReleaseYear: 2016, PosterPath: "/abc123.jpg"}}
+	newPosterModel := func(protocol string) tea.Model {
+		m := newModel(&Client{
+			Posters: &fakePosterService{img: mustLoadPoster(t, "poster.png")},
+			Config:  &ClientConfig{PosterProtocol: protocol},
+		}, movies)
+		m.posterOut = &bytes.Buffer{}
+		return m
+	}
+	// update sends the message, and feeds back whatever poster message the
+	// command comes back with
+	update := func(m tea.Model, msg tea.Msg) tea.Model {
+		m, cmd := m.Update(msg)
+		require.NotNil(t, cmd)
+		next := cmd()
+		switch next.(type) {
+		case posterMsg, posterDrawMsg:
+		default:
+			t.Fatalf("unexpected message: %#v", next)
+		}
+		m, _ = m.Update(next)
+		return m
+	}
+
+	m := update(newPosterModel(PosterHalfBlock), tea.WindowSizeMsg{Width: 120, Height: 40})
+	require.Contains(t, m.View(), "▀")
+	require.Contains(t, m.View(), "The Handmaiden (2016)")
+
+	m = newPosterModel(PosterKitty)
+	m = update(m, tea.WindowSizeMsg{Width: 120, Height: 40})
+	require.NotContains(t, m.View(), "\x1b_G")
+	// Once loaded, it is drawn over the detail pane
+	m = update(m, tea.WindowSizeMsg{Width: 120, Height: 40})
+	buf := m.(model).posterOut.(*bytes.Buffer)
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, kittyClear+"\x1b7\x1b["))
+	require.Contains(t, out, "\x1b_Ga=t,f=100")
+	require.Contains(t, out, "\x1b_Ga=p,")
+
+	// Nothing has moved, so it isn't drawn again
+	require.Nil(t, m.(model).posterCmd(progressMsg{Collected: 1}))
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	require.Nil(t, m.(model).posterCmd(tea.KeyMsg{Type: tea.KeyDown}))
+	// After a resize it is placed again, without sending the image again
+	buf.Reset()
+	m = update(m, tea.WindowSizeMsg{Width: 100, Height: 40})
+	out = buf.String()
+	require.Contains(t, out, "\x1b_Ga=p,")
+	require.NotContains(t, out, "\x1b_Ga=t")
+
+	// With a bracket open its posters are loaded, not the list's
+	movies = []*Movie{movies[0], {Title: "Oldboy", ReleaseYear: 2003}}
+	m, cmd := newPosterModel(PosterHalfBlock).Update(keyPress("B"))
+	require.NotNil(t, m.(model).bracket)
+	require.NotNil(t, cmd)
+	require.True(t, m.(model).posters["/abc123.jpg"].loading)
+
+	// Nothing is loaded or drawn with posters off
+	m = newPosterModel(PosterNone)
+	_, cmd = m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	require.Nil(t, cmd)
+}
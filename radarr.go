@@ -1,10 +1,15 @@
 package letswatch
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/drewstinnett/go-letterboxd"
 	"github.com/rs/zerolog/log"
@@ -15,10 +20,10 @@ type RadarrService interface {
 	QualityProfiles() ([]*radarr.QualityProfile, error)
 	QualityProfileWithName(string) (*radarr.QualityProfile, error)
 	AddTag(string) (int, error)
-	MustAddTag(string) int
 	MoviesWithTMDBID(int64) ([]*radarr.Movie, error)
 	AddMovie(*radarr.AddMovieInput) (*radarr.AddMovieOutput, error)
 	MovieInputWithLetterboxdFilm(*letterboxd.Film) (*radarr.AddMovieInput, error)
+	Requester
 }
 
 type RadarrServiceOp struct {
@@ -64,15 +69,6 @@ func (svc *RadarrServiceOp) AddTag(t string) (int, error) {
 	return svc.radarrClient.AddTag(t)
 }
 
-func (svc *RadarrServiceOp) MustAddTag(t string) int {
-	i, err := svc.AddTag(t)
-	if err != nil {
-		log.Warn().Err(err).Str("tag", t).Msg("Error getting tag")
-		panic(err)
-	}
-	return i
-}
-
 func (svc *RadarrServiceOp) MovieInputWithLetterboxdFilm(item *letterboxd.Film) (*radarr.AddMovieInput, error) {
 	// Figure out tmdb id in a usable format
 	tmdbID, err := strconv.ParseInt(item.ExternalIDs.TMDB, 10, 64)
@@ -83,7 +79,10 @@ func (svc *RadarrServiceOp) MovieInputWithLetterboxdFilm(item *letterboxd.Film)
 	if err != nil {
 		return nil, err
 	}
-	tagID := svc.MustAddTag("letswatch-supplement")
+	tagID, err := svc.AddTag("letswatch-supplement")
+	if err != nil {
+		return nil, err
+	}
 	mi := &radarr.AddMovieInput{
 		Title:            item.Title,
 		Year:             item.Year,
@@ -99,6 +98,57 @@ func (svc *RadarrServiceOp) MovieInputWithLetterboxdFilm(item *letterboxd.Film)
 	return mi, nil
 }
 
+// RequestFilm adds the film to Radarr and kicks off a search for it
+func (svc *RadarrServiceOp) RequestFilm(ctx context.Context, item *letterboxd.Film) error {
+	mi, err := svc.MovieInputWithLetterboxdFilm(item)
+	if err != nil {
+		return err
+	}
+	_, err = svc.AddMovie(mi)
+	return err
+}
+
+// AddToRadarr adds a single movie to Radarr, whatever the request backend is
+func (c *Client) AddToRadarr(ctx context.Context, m *Movie) error {
+	if err := c.Radarr.RequestFilm(ctx, m.Film()); err != nil {
+		return err
+	}
+	c.recordHistory(m.IMDBID, m.Title, m.ReleaseYear, HistoryAdded)
+	return nil
+}
+
+// RequestStatus returns available if Radarr has a file for the film, and
+// processing if it is only being monitored
+func (svc *RadarrServiceOp) RequestStatus(ctx context.Context, tmdbID int64) (RequestStatus, error) {
+	movies, err := svc.MoviesWithTMDBID(tmdbID)
+	if err != nil {
+		return RequestStatusNone, err
+	}
+	if len(movies) == 0 {
+		return RequestStatusNone, nil
+	}
+	for _, m := range movies {
+		if m.HasFile {
+			return RequestStatusAvailable, nil
+		}
+	}
+	return RequestStatusProcessing, nil
+}
+
+// NewRadarrMovieWithFilm converts a Letterboxd film to the StevenLu style
+// entry used by Radarr custom lists
+func NewRadarrMovieWithFilm(f *letterboxd.Film) RadarrMovie {
+	m := RadarrMovie{
+		Title:       f.Title,
+		ReleaseYear: f.Year,
+	}
+	if f.ExternalIDs != nil {
+		m.IMDBID = f.ExternalIDs.IMDB
+		m.ID, _ = strconv.ParseFloat(f.ExternalIDs.TMDB, 64)
+	}
+	return m
+}
+
 func ParseRadarrMovies(data []byte) ([]RadarrMovie, error) {
 	var movies []RadarrMovie
 	err := json.Unmarshal(data, &movies)
@@ -112,3 +162,81 @@ func ParseRadarrMoviesWithFile(fileP string) ([]RadarrMovie, error) {
 	}
 	return ParseRadarrMovies(data)
 }
+
+// maxJSONListSize is the most of a remote JSON list that will be read. Big
+// lists run to a few MB
+const maxJSONListSize = 20 << 20
+
+// ParseRadarrMoviesWithURL fetches and parses a StevenLu style list, like the
+// ones MDBList and similar tools publish
+func ParseRadarrMoviesWithURL(ctx context.Context, client *http.Client, u string) ([]RadarrMovie, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned %v", u, res.StatusCode)
+	}
+	// Read one byte past the limit, to tell a list that is too big from one
+	// that is exactly the limit
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxJSONListSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxJSONListSize {
+		return nil, fmt.Errorf("%v is bigger than %v bytes", u, maxJSONListSize)
+	}
+	return ParseRadarrMovies(data)
+}
+
+// isURL returns true if the source looks like an http(s) URL rather than a file
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// JSONListFilms reads a StevenLu/Radarr style JSON list from a file or URL,
+// and returns the entries as films so they can go through the same pipeline as
+// Letterboxd lists
+func (c *Client) JSONListFilms(ctx context.Context, source string) ([]*letterboxd.Film, error) {
+	var movies []RadarrMovie
+	var err error
+	if isURL(source) {
+		movies, err = ParseRadarrMoviesWithURL(ctx, c.httpClient(ServiceLists), source)
+	} else {
+		movies, err = ParseRadarrMoviesWithFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := []*letterboxd.Film{}
+	for _, m := range movies {
+		ret = append(ret, m.Film())
+	}
+	log.Debug().Str("source", source).Int("count", len(ret)).Msg("Read JSON list")
+	return ret, nil
+}
+
+// Film converts the entry in to a Letterboxd film, keeping the IMDB ID so it
+// can go straight to TMDB
+func (r RadarrMovie) Film() *letterboxd.Film {
+	f := &letterboxd.Film{
+		Title: r.Title,
+		Year:  r.ReleaseYear,
+		ExternalIDs: &letterboxd.FilmExternalIDs{
+			IMDB: r.IMDBID,
+		},
+	}
+	// The id in these lists is the TMDB ID
+	if r.ID > 0 {
+		f.ExternalIDs.TMDB = strconv.FormatFloat(r.ID, 'f', -1, 64)
+	}
+	return f
+}
@@ -1,8 +1,14 @@
 package letswatch
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
 	"testing"
 
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -11,3 +17,52 @@ func TestParseRadarrMoviesWithFile(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, len(movies), 250)
 }
+
+func TestNewRadarrMovieWithFilm(t *testing.T) {
+	got, err := json.Marshal([]RadarrMovie{NewRadarrMovieWithFilm(&letterboxd.Film{
+		Title:       "The Handmaiden",
+		Year:        2016,
+		ExternalIDs: &letterboxd.FilmExternalIDs{IMDB: "tt4016934", TMDB: "290098"},
+	})})
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"id":290098,"title":"The Handmaiden","imdb_id":"tt4016934","release_year":"2016"}]`, string(got))
+
+	// Make sure we can read back what we write
+	movies, err := ParseRadarrMovies(got)
+	require.NoError(t, err)
+	require.Equal(t, "tt4016934", movies[0].IMDBID)
+}
+
+func TestJSONListFilms(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	top250, err := ioutil.ReadFile("testdata/top250.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "https://mdblist.example.com/lists/top250/json",
+		httpmock.NewStringResponder(200, string(top250)))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+
+	for _, source := range []string{"testdata/top250.json", "https://mdblist.example.com/lists/top250/json"} {
+		films, err := c.JSONListFilms(context.Background(), source)
+		require.NoError(t, err, source)
+		require.Equal(t, 250, len(films), source)
+		require.Equal(t, "tt6710474", films[0].ExternalIDs.IMDB, source)
+		require.Equal(t, "545611", films[0].ExternalIDs.TMDB, source)
+	}
+
+	_, err = c.JSONListFilms(context.Background(), "https://mdblist.example.com/missing")
+	require.Error(t, err)
+
+	httpmock.RegisterResponder("GET", "https://mdblist.example.com/lists/huge/json",
+		httpmock.NewStringResponder(200, "["+strings.Repeat(" ", maxJSONListSize)+"]"))
+	_, err = c.JSONListFilms(context.Background(), "https://mdblist.example.com/lists/huge/json")
+	require.EqualError(t, err, "https://mdblist.example.com/lists/huge/json is bigger than 20971520 bytes")
+}
@@ -0,0 +1,374 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	tmdb "github.com/cyruzin/golang-tmdb"
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// How many of the top billed cast to keep on a Movie
+const movieCastSize = 5
+
+// NewMovieWithTMDB converts TMDB movie details in to a Movie. Streaming and
+// media server availability are not filled in, as they need more lookups
+func NewMovieWithTMDB(m *tmdb.MovieDetails) *Movie {
+	ret := &Movie{
+		Title:      m.Title,
+		IMDBID:     m.IMDbID,
+		IMDBLink:   fmt.Sprintf("https://www.imdb.com/title/%s", m.IMDbID),
+		TMDBID:     fmt.Sprint(m.ID),
+		Language:   m.OriginalLanguage,
+		Budget:     float64(m.Budget) / float64(1000000),
+		RunTime:    time.Duration(m.Runtime) * time.Minute,
+		Rating:     float64(m.VoteAverage),
+		Overview:   m.Overview,
+		PosterPath: m.PosterPath,
+		Genres:     []string{},
+	}
+	if len(m.ReleaseDate) >= 4 {
+		ret.ReleaseYear, _ = strconv.Atoi(m.ReleaseDate[0:4])
+	}
+	if m.MovieCreditsAppend != nil && m.MovieCreditsAppend.Credits.MovieCredits != nil {
+		for _, i := range m.MovieCreditsAppend.Credits.Crew {
+			if i.Job == "Director" {
+				ret.Directors = append(ret.Directors, i.Name)
+			}
+		}
+		for _, i := range m.MovieCreditsAppend.Credits.Cast {
+			if len(ret.Cast) == movieCastSize {
+				break
+			}
+			ret.Cast = append(ret.Cast, i.Name)
+		}
+	}
+	for _, genre := range m.Genres {
+		ret.Genres = append(ret.Genres, genre.Name)
+	}
+	if m.MovieKeywordsAppend != nil && m.MovieKeywordsAppend.Keywords.MovieKeywords != nil {
+		for _, k := range m.MovieKeywordsAppend.Keywords.Keywords {
+			ret.Keywords = append(ret.Keywords, k.Name)
+		}
+	}
+	return ret
+}
+
+// fillStreaming looks up where the movie is streaming, and which of those are
+// services I subscribe to
+func (c *Client) fillStreaming(movie *Movie, me *PersonInfo) error {
+	id, err := strconv.Atoi(movie.TMDBID)
+	if err != nil {
+		return err
+	}
+	streaming, err := c.TMDB.GetStreamingChannels(id)
+	if err != nil {
+		return err
+	}
+	movie.StreamingOn = streaming
+	movie.StreamingOnMy = Intersection(me.SubscribedTo, streaming)
+	return nil
+}
+
+// fillAvailableOn looks up which of my media servers have the movie
+func (c *Client) fillAvailableOn(ctx context.Context, movie *Movie) {
+	movie.AvailableOn = c.AvailableOn(ctx, movie.Title, movie.ReleaseYear)
+	movie.OnPlex = ContainsString(movie.AvailableOn, MediaServerPlex)
+}
+
+// MovieWithIMDBID returns a fully populated Movie for the given IMDB ID
+func (c *Client) MovieWithIMDBID(ctx context.Context, imdbID string, me *PersonInfo) (*Movie, error) {
+	m, err := c.TMDB.GetWithIMDBID(ctx, imdbID)
+	if err != nil {
+		return nil, err
+	}
+	return c.movieWithDetails(ctx, m, me), nil
+}
+
+// MovieWithTMDBID returns a fully populated Movie for the given TMDB ID
+func (c *Client) MovieWithTMDBID(ctx context.Context, tmdbID int, me *PersonInfo) (*Movie, error) {
+	m, err := c.TMDB.GetWithTMDBID(ctx, tmdbID)
+	if err != nil {
+		return nil, err
+	}
+	return c.movieWithDetails(ctx, m, me), nil
+}
+
+// FillDetails fills in everything on a movie that StreamRecommendations only
+// looks up when a filter needs it, like media server availability and the
+// request status
+func (c *Client) FillDetails(ctx context.Context, movie *Movie) {
+	if movie.AvailableOn == nil {
+		c.fillAvailableOn(ctx, movie)
+	}
+	if c.Requester == nil || movie.RequestStatus != "" {
+		return
+	}
+	id, err := strconv.ParseInt(movie.TMDBID, 10, 64)
+	if err != nil {
+		return
+	}
+	status, err := c.Requester.RequestStatus(ctx, id)
+	if err != nil {
+		log.Warn().Err(err).Str("title", movie.Title).Msg("Error getting request status")
+		return
+	}
+	movie.RequestStatus = status.String()
+}
+
+func (c *Client) movieWithDetails(ctx context.Context, m *tmdb.MovieDetails, me *PersonInfo) *Movie {
+	movie := NewMovieWithTMDB(m)
+	if err := c.fillStreaming(movie, me); err != nil {
+		log.Warn().Err(err).Str("title", movie.Title).Msg("Error getting streaming channels")
+	}
+	c.fillAvailableOn(ctx, movie)
+	return movie
+}
+
+// CollectFilms pulls in all the films from the lists, JSON lists and
+// optionally the watchlist, in the collect options
+func (c *Client) CollectFilms(ctx context.Context, me *PersonInfo, collect *MovieCollectOpts) ([]*letterboxd.Film, error) {
+	isoBatchFilter := &letterboxd.FilmBatchOpts{}
+	if len(collect.Lists) > 0 {
+		log.Info().Msg("Getting lists")
+		isoBatchFilter.List = collect.Lists
+	}
+	if collect.Watchlist {
+		log.Info().Msg("Adding Watchlist to ISO")
+		isoBatchFilter.WatchList = []string{me.LetterboxdUsername}
+	}
+	ret := []*letterboxd.Film{}
+	for _, source := range collect.JSONLists {
+		log.Info().Str("source", source).Msg("Getting JSON list")
+		films, err := c.JSONListFilms(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, films...)
+	}
+	if len(isoBatchFilter.List) == 0 && len(isoBatchFilter.WatchList) == 0 {
+		return ret, nil
+	}
+
+	films, err := c.letterboxdFilms(ctx, isoBatchFilter)
+	if err != nil {
+		return nil, err
+	}
+	return append(ret, films...), nil
+}
+
+// RecommendProgress counts how far StreamRecommendations has got
+type RecommendProgress struct {
+	// Collected is how many films were found on the lists
+	Collected int
+	// Enriched is how many have been looked up on TMDB
+	Enriched int
+	// Filtered is how many have been removed, for any reason
+	Filtered int
+	// Sent is how many have been recommended
+	Sent int
+}
+
+// StreamRecommendations collects the films in the collect options, filters
+// them down and sends each remaining film to movieC as a fully populated
+// Movie. movieC is closed once everything has been sent
+func (c *Client) StreamRecommendations(ctx context.Context, me *PersonInfo, filter *MovieFilterOpts, collect *MovieCollectOpts, movieC chan<- *Movie) error {
+	return c.StreamRecommendationsWithProgress(ctx, me, filter, collect, movieC, nil)
+}
+
+// StreamRecommendationsWithProgress is StreamRecommendations, but calls
+// progress, if set, each time a film has been dealt with
+func (c *Client) StreamRecommendationsWithProgress(ctx context.Context, me *PersonInfo, filter *MovieFilterOpts, collect *MovieCollectOpts, movieC chan<- *Movie, progress func(RecommendProgress)) error {
+	defer close(movieC)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if filter.OnlyNew && c.Store == nil {
+		return errors.New("only-new needs the state store, set 'state_db' in the config")
+	}
+	isoFilms, err := c.CollectFilms(ctx, me, collect)
+	if err != nil {
+		return err
+	}
+	prog := RecommendProgress{Collected: len(isoFilms)}
+	report := func() {
+		if progress != nil {
+			progress(prog)
+		}
+	}
+	report()
+
+	// Collect watched films first. They are still looked up when watched films
+	// are included, so we can say where they were watched
+	watched := WatchedSet{}
+	if !filter.IncludeWatched {
+		log.Info().Msg("Getting watched films")
+		watched, err = c.Watched(ctx, me.LetterboxdUsername)
+		if err != nil {
+			return err
+		}
+	} else if me.LetterboxdUsername != "" {
+		log.Info().Msg("Getting watched films")
+		if watched, err = c.Watched(ctx, me.LetterboxdUsername); err != nil {
+			log.Warn().Err(err).Msg("Error getting watched films, they won't be marked")
+			watched = WatchedSet{}
+		}
+	}
+
+	watchedRemoved := map[string]int{}
+	for i, item := range isoFilms {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Anything dealt with so far that wasn't sent was filtered out
+		prog.Filtered = i - prog.Sent
+		report()
+		ref := FilmRefWithLetterboxd(item)
+		imdbID := ref.IMDBID
+		slog := log.With().Str("film", item.Title).Str("imdbid", imdbID).Str("tmdbid", ref.TMDBID).Logger()
+
+		// skip checks the things that need the IMDB ID
+		skip := func(imdbID string) (bool, error) {
+			// Filter watched films if specified
+			if !filter.IncludeWatched && watched.Contains(imdbID) {
+				sources := watched.Sources(imdbID)
+				slog.Debug().Strs("sources", sources).Msg("Already watched")
+				for _, source := range sources {
+					watchedRemoved[source]++
+				}
+				return true, nil
+			}
+			if c.IsDismissed(imdbID) {
+				slog.Debug().Msg("Dismissed")
+				return true, nil
+			}
+			if filter.OnlyNew {
+				h, err := c.Store.Get(imdbID)
+				if err != nil {
+					return false, err
+				}
+				if h.Has(HistoryRecommended) {
+					slog.Debug().Time("first-recommended", h.Events[HistoryRecommended]).Msg("Already recommended")
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		if imdbID != "" {
+			if skipped, err := skip(imdbID); err != nil {
+				return err
+			} else if skipped {
+				continue
+			}
+		}
+		// Do some checking on the year
+		if !filter.MatchesYear(item.Year) {
+			slog.Debug().Int("year", item.Year).Msg("Outside of the release years we want")
+			continue
+		}
+
+		// Populate with TMDB Data
+		m, err := c.ResolveFilm(ctx, ref)
+		if err != nil {
+			slog.Warn().Err(err).Msg("Error getting movie from TMDB")
+			continue
+		}
+		// Films without an IMDB ID on the list can be checked now TMDB has
+		// given us one
+		if imdbID == "" && m.IMDbID != "" {
+			if skipped, err := skip(m.IMDbID); err != nil {
+				return err
+			} else if skipped {
+				continue
+			}
+		}
+		prog.Enriched++
+		movie := NewMovieWithTMDB(m)
+		// Prefer the Letterboxd title and year, that is what we match on elsewhere
+		movie.Title = item.Title
+		movie.ReleaseYear = item.Year
+		// Marked by the same ID it was checked against
+		if imdbID != "" {
+			movie.WatchedOn = watched.Sources(imdbID)
+		} else {
+			movie.WatchedOn = watched.Sources(m.IMDbID)
+		}
+
+		if !filter.Matches(movie) {
+			continue
+		}
+
+		// Ok, looks good, lets find where it's streaming
+		if err := c.fillStreaming(movie, me); err != nil {
+			slog.Warn().Err(err).Msg("Error getting streaming channels")
+		}
+		if filter.OnlyMyStreaming {
+			// Collect movies we have in Plex, Jellyfin or Emby
+			c.fillAvailableOn(ctx, movie)
+			if len(movie.AvailableOn) == 0 && len(movie.StreamingOnMy) == 0 {
+				slog.Debug().Strs("streaming", movie.StreamingOn).Strs("my-streaming", me.SubscribedTo).Msg("Film not on any of my streaming subscriptions or media servers")
+				continue
+			}
+		} else if filter.OnlyNotMyStreaming {
+			c.fillAvailableOn(ctx, movie)
+			if len(movie.StreamingOnMy) > 0 || len(movie.AvailableOn) > 0 {
+				slog.Debug().Strs("streaming", movie.StreamingOn).Strs("my-streaming", me.SubscribedTo).Msg("Film is on one of my streaming subscriptions")
+				continue
+			}
+		}
+
+		select {
+		case movieC <- movie:
+			prog.Sent++
+			// A consumer that has stopped the stream may not have kept it.
+			// It records the ones it did with RecordRecommended
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.recordHistory(movie.IMDBID, movie.Title, movie.ReleaseYear, HistoryRecommended)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	prog.Filtered = len(isoFilms) - prog.Sent
+	report()
+	if len(watchedRemoved) > 0 {
+		log.Info().Interface("sources", watchedRemoved).Msg("Removed already watched films")
+	}
+	return nil
+}
+
+// Recommend is StreamRecommendations, but returns everything at once
+func (c *Client) Recommend(ctx context.Context, me *PersonInfo, filter *MovieFilterOpts, collect *MovieCollectOpts) ([]*Movie, error) {
+	movieC := make(chan *Movie)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.StreamRecommendations(ctx, me, filter, collect, movieC)
+	}()
+	ret := []*Movie{}
+	for m := range movieC {
+		ret = append(ret, m)
+	}
+	return ret, <-errC
+}
+
+var (
+	pickRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	pickMu   sync.Mutex
+)
+
+// PickMovie returns a random movie from the given list, or nil if it's empty
+func PickMovie(movies []*Movie) *Movie {
+	if len(movies) == 0 {
+		return nil
+	}
+	pickMu.Lock()
+	defer pickMu.Unlock()
+	return movies[pickRand.Intn(len(movies))]
+}
@@ -0,0 +1,147 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+// newRecommendTestClient has a JSON list of films, two of which are found on
+// TMDB
+func newRecommendTestClient(t *testing.T) (*Client, string) {
+	findRes, err := ioutil.ReadFile("testdata/find_tmdb.json")
+	require.NoError(t, err)
+	movieDetails, err := ioutil.ReadFile("testdata/movie_details.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/find/",
+		httpmock.NewStringResponder(200, string(findRes)))
+	httpmock.RegisterResponder("GET", "https://api.themoviedb.org/3/movie/290098",
+		httpmock.NewStringResponder(200, string(movieDetails)))
+	httpmock.RegisterResponder("GET", "https://api.themoviedb.org/3/movie/290098/watch/providers",
+		httpmock.NewStringResponder(200, `{"id":290098,"results":{"US":{"flatrate":[{"provider_name":"Shudder"}]}}}`))
+
+	list := filepath.Join(t.TempDir(), "list.json")
+	require.NoError(t, os.WriteFile(list, []byte(`[
+		{"id":290098,"imdb_id":"tt4016934","title":"The Handmaiden","release_year":"2016"},
+		{"id":11075,"imdb_id":"tt0235198","title":"Audition","release_year":"1999"},
+		{"id":1,"title":"No IMDB","release_year":"2016"},
+		{"id":290098,"imdb_id":"tt0000002","title":"The Handmaiden Again","release_year":"2016"}
+	]`), 0o600))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+	return c, list
+}
+
+func TestStreamRecommendationsWithProgress(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c, list := newRecommendTestClient(t)
+
+	var got []RecommendProgress
+	movieC := make(chan *Movie)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.StreamRecommendationsWithProgress(context.Background(), &PersonInfo{SubscribedTo: []string{"Shudder"}},
+			&MovieFilterOpts{Earliest: 2000, IncludeWatched: true},
+			&MovieCollectOpts{JSONLists: []string{list}},
+			movieC, func(p RecommendProgress) { got = append(got, p) })
+	}()
+	var movies []*Movie
+	for m := range movieC {
+		movies = append(movies, m)
+	}
+	require.NoError(t, <-errC)
+	require.Len(t, movies, 2)
+	require.Equal(t, []string{"Shudder"}, movies[0].StreamingOnMy)
+
+	require.Equal(t, RecommendProgress{Collected: 4}, got[0])
+	require.Equal(t, RecommendProgress{Collected: 4, Enriched: 2, Filtered: 2, Sent: 2}, got[len(got)-1])
+}
+
+func TestStreamRecommendationsStopped(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c, list := newRecommendTestClient(t)
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	c.Store = store
+
+	// Stopping after the first film, the way candidateMovies does
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	movieC := make(chan *Movie)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.StreamRecommendations(ctx, &PersonInfo{}, &MovieFilterOpts{Earliest: 2000, IncludeWatched: true},
+			&MovieCollectOpts{JSONLists: []string{list}}, movieC)
+	}()
+	var kept []*Movie
+	for m := range movieC {
+		if len(kept) == 0 {
+			kept = append(kept, m)
+			cancel()
+		}
+	}
+	require.ErrorIs(t, <-errC, context.Canceled)
+	c.RecordRecommended(kept...)
+
+	h, err := store.Get("tt4016934")
+	require.NoError(t, err)
+	require.True(t, h.Has(HistoryRecommended))
+	h, err = store.Get("tt0000002")
+	require.NoError(t, err)
+	require.False(t, h.Has(HistoryRecommended))
+}
+
+// watchedFilms is a Letterboxd film service that has only watched the given
+// films
+type watchedFilms struct {
+	letterboxd.FilmService
+	ids []string
+}
+
+func (w watchedFilms) GetWatchedIMDBIDs(context.Context, string) ([]string, error) {
+	return w.ids, nil
+}
+
+func TestStreamRecommendationsWatchedOn(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c, list := newRecommendTestClient(t)
+	c.LetterboxdClient.Film = watchedFilms{FilmService: c.LetterboxdClient.Film, ids: []string{"tt4016934"}}
+
+	recommend := func(filter *MovieFilterOpts) []*Movie {
+		movieC := make(chan *Movie)
+		errC := make(chan error, 1)
+		go func() {
+			errC <- c.StreamRecommendations(context.Background(), &PersonInfo{LetterboxdUsername: "dave"}, filter,
+				&MovieCollectOpts{JSONLists: []string{list}}, movieC)
+		}()
+		var movies []*Movie
+		for m := range movieC {
+			movies = append(movies, m)
+		}
+		require.NoError(t, <-errC)
+		return movies
+	}
+
+	// Watched films say where they were watched when they're included
+	movies := recommend(&MovieFilterOpts{Earliest: 2000, IncludeWatched: true})
+	require.Len(t, movies, 2)
+	require.Equal(t, []string{WatchedSourceLetterboxd}, movies[0].WatchedOn)
+	require.Empty(t, movies[1].WatchedOn)
+
+	require.Equal(t, []string{"The Handmaiden Again"}, movieTitles(recommend(&MovieFilterOpts{Earliest: 2000})))
+}
@@ -0,0 +1,81 @@
+package letswatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// Requester is anything that can take a film request and tell us what
+// happened to previous ones. Radarr and Overseerr/Jellyseerr both fit
+type Requester interface {
+	RequestFilm(context.Context, *letterboxd.Film) error
+	RequestStatus(context.Context, int64) (RequestStatus, error)
+}
+
+// RequestStatus is the state of a film in a request backend
+type RequestStatus int
+
+const (
+	RequestStatusNone RequestStatus = iota
+	RequestStatusPending
+	RequestStatusProcessing
+	RequestStatusAvailable
+)
+
+func (s RequestStatus) String() string {
+	switch s {
+	case RequestStatusPending:
+		return "pending"
+	case RequestStatusProcessing:
+		return "processing"
+	case RequestStatusAvailable:
+		return "available"
+	default:
+		return "none"
+	}
+}
+
+// IsRequested returns true if the backend already knows about the film
+func (s RequestStatus) IsRequested() bool {
+	return s != RequestStatusNone
+}
+
+// Names of the supported request backends, as used in the config
+const (
+	RequestBackendRadarr     = "radarr"
+	RequestBackendOverseerr  = "overseerr"
+	RequestBackendJellyseerr = "jellyseerr"
+)
+
+// requesterWithBackend returns the Requester for the given backend name
+func (c *Client) requesterWithBackend(backend string) (Requester, error) {
+	switch strings.ToLower(backend) {
+	case "", RequestBackendRadarr:
+		return c.Radarr, nil
+	case RequestBackendOverseerr, RequestBackendJellyseerr:
+		return c.Overseerr, nil
+	default:
+		return nil, fmt.Errorf("unknown request backend: %v", backend)
+	}
+}
+
+// RequestFilms requests each film in turn, stopping at the first error. The
+// films that were requested before any error are returned
+func (c *Client) RequestFilms(ctx context.Context, films []*letterboxd.Film) ([]*letterboxd.Film, error) {
+	ret := []*letterboxd.Film{}
+	for _, f := range films {
+		log.Info().Str("title", f.Title).Int("year", f.Year).Msg("Requesting")
+		if err := c.Requester.RequestFilm(ctx, f); err != nil {
+			return ret, err
+		}
+		ret = append(ret, f)
+		if f.ExternalIDs != nil {
+			c.recordHistory(f.ExternalIDs.IMDB, f.Title, f.Year, HistoryAdded)
+		}
+	}
+	return ret, nil
+}
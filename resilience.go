@@ -0,0 +1,442 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Services that calls are made to, each with its own retry policy and circuit
+// breaker
+const (
+	ServiceTMDB       = "tmdb"
+	ServicePlex       = "plex"
+	ServiceRadarr     = "radarr"
+	ServiceLetterboxd = "letterboxd"
+	ServiceOverseerr  = "overseerr"
+	ServicePosters    = "posters"
+	ServiceLists      = "lists"
+	ServiceNotify     = "notify"
+)
+
+// RetryPolicy is how calls to a service are retried, timed out and cut off
+// when it keeps failing. Zero values use DefaultRetryPolicy
+type RetryPolicy struct {
+	// Retries is how many more times a failed call is tried
+	Retries int `mapstructure:"retries"`
+	// BaseDelay doubles each retry, up to MaxDelay, with jitter
+	BaseDelay time.Duration `mapstructure:"base_delay"`
+	MaxDelay  time.Duration `mapstructure:"max_delay"`
+	// Timeout is for each try, not all of them
+	Timeout time.Duration `mapstructure:"timeout"`
+	// BreakAfter calls failing in a row opens the circuit, failing calls
+	// straight away for Cooldown
+	BreakAfter int           `mapstructure:"break_after"`
+	Cooldown   time.Duration `mapstructure:"cooldown"`
+}
+
+// DefaultRetryPolicy is used for anything a service's policy doesn't set
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:    3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	Timeout:    30 * time.Second,
+	BreakAfter: 5,
+	Cooldown:   time.Minute,
+}
+
+// defaultServiceTimeouts are the timeouts of services that differ from
+// DefaultRetryPolicy
+var defaultServiceTimeouts = map[string]time.Duration{
+	ServiceTMDB: 10 * time.Second,
+	ServicePlex: 15 * time.Second,
+	// Letterboxd calls scrape a whole list or watch history
+	ServiceLetterboxd: 5 * time.Minute,
+}
+
+// withDefaults fills in what p doesn't set from def
+func (p RetryPolicy) withDefaults(def RetryPolicy) RetryPolicy {
+	if p.Retries == 0 {
+		p.Retries = def.Retries
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Timeout == 0 {
+		p.Timeout = def.Timeout
+	}
+	if p.BreakAfter == 0 {
+		p.BreakAfter = def.BreakAfter
+	}
+	if p.Cooldown == 0 {
+		p.Cooldown = def.Cooldown
+	}
+	// Negative turns retries off
+	if p.Retries < 0 {
+		p.Retries = 0
+	}
+	return p
+}
+
+// total is the longest every try and the waits between them can take
+func (p RetryPolicy) total() time.Duration {
+	return p.Timeout*time.Duration(p.Retries+1) + p.MaxDelay*time.Duration(p.Retries)
+}
+
+// CircuitOpenError is returned straight away for calls to a service that has
+// been failing, until its cooldown is over
+type CircuitOpenError struct {
+	Service string
+	Until   time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%v is unavailable after repeated failures, not trying again until %v", e.Service, e.Until.Format("15:04:05"))
+}
+
+// breaker is a service's circuit breaker. The circuit opens after enough
+// failures in a row, and after the cooldown lets a call through to see if the
+// service is back
+type breaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// Resilience retries failed calls, with backoff, and stops calling services
+// that are down. It is shared by everything a Client calls out to
+type Resilience struct {
+	mu       sync.Mutex
+	policies map[string]RetryPolicy
+	breakers map[string]*breaker
+	retries  map[string]int
+	rand     *rand.Rand
+	now      func() time.Time
+	sleep    func(context.Context, time.Duration) error
+}
+
+// NewResilience returns a Resilience using the policies by service
+func NewResilience(policies map[string]RetryPolicy) *Resilience {
+	r := &Resilience{
+		policies: map[string]RetryPolicy{},
+		breakers: map[string]*breaker{},
+		retries:  map[string]int{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		now:      time.Now,
+		sleep:    sleepContext,
+	}
+	for service, p := range policies {
+		r.policies[service] = p
+	}
+	return r
+}
+
+// Policy is the retry policy for a service
+func (r *Resilience) Policy(service string) RetryPolicy {
+	def := DefaultRetryPolicy
+	if t, ok := defaultServiceTimeouts[service]; ok {
+		def.Timeout = t
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.policies[service].withDefaults(def)
+}
+
+// Retries is how many times calls to each service have been retried
+func (r *Resilience) Retries() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := map[string]int{}
+	for service, n := range r.retries {
+		ret[service] = n
+	}
+	return ret
+}
+
+// Unavailable are the services whose circuit is open
+func (r *Resilience) Unavailable() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := []string{}
+	now := r.now()
+	for service, b := range r.breakers {
+		if now.Before(b.openUntil) {
+			ret = append(ret, service)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// allow returns a CircuitOpenError if the service's circuit is open
+func (r *Resilience) allow(service string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b := r.breakers[service]; b != nil && r.now().Before(b.openUntil) {
+		return &CircuitOpenError{Service: service, Until: b.openUntil}
+	}
+	return nil
+}
+
+// record counts a call that is done with, retries and all. A failure while
+// the service is being tried again after a cooldown opens the circuit again
+func (r *Resilience) record(service string, failed bool) {
+	policy := r.Policy(service)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.breakers[service]
+	if b == nil {
+		b = &breaker{}
+		r.breakers[service] = b
+	}
+	if !failed {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= policy.BreakAfter {
+		b.openUntil = r.now().Add(policy.Cooldown)
+		log.Warn().Str("service", service).Int("failures", b.failures).Time("until", b.openUntil).Msg("Service keeps failing, giving it a rest")
+	}
+}
+
+func (r *Resilience) addRetry(service string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[service]++
+}
+
+// backoff is how long to wait before retry number attempt, from 0. It doubles
+// each time, with up to half of it taken off at random so clients don't retry
+// in step
+func (r *Resilience) backoff(policy RetryPolicy, attempt int) time.Duration {
+	d := policy.BaseDelay << uint(attempt)
+	if d <= 0 || d > policy.MaxDelay {
+		d = policy.MaxDelay
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return d/2 + time.Duration(r.rand.Int63n(int64(d/2)+1))
+}
+
+// Do calls fn until it succeeds, retrying with backoff, for calls made by
+// libraries that don't take an http.Client. Each try gets the service's
+// timeout. A nil Resilience just calls fn
+func (r *Resilience) Do(ctx context.Context, service string, fn func(context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r == nil {
+		return fn(ctx)
+	}
+	if err := r.allow(service); err != nil {
+		return err
+	}
+	policy := r.Policy(service)
+	for attempt := 0; ; attempt++ {
+		tctx, cancel := context.WithTimeout(ctx, policy.Timeout)
+		err := fn(tctx)
+		cancel()
+		if err == nil {
+			r.record(service, false)
+			return nil
+		}
+		if attempt >= policy.Retries || ctx.Err() != nil {
+			r.record(service, true)
+			return err
+		}
+		delay := r.backoff(policy, attempt)
+		log.Debug().Err(err).Str("service", service).Int("attempt", attempt+1).Dur("delay", delay).Msg("Call failed, retrying")
+		r.addRetry(service)
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Client returns a copy of base whose requests go through the service's
+// retries and circuit breaker. Its Timeout covers all of the tries, each of
+// which has the policy's timeout
+func (r *Resilience) Client(service string, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	ret := *base
+	ret.Timeout = r.Policy(service).total()
+	ret.Transport = &resilientTransport{
+		resilience: r,
+		service:    service,
+		base:       base.Transport,
+	}
+	return &ret
+}
+
+// resilientTransport retries requests that fail in ways that might not
+// happen again: network errors, rate limits and gateway errors
+type resilientTransport struct {
+	resilience *Resilience
+	service    string
+	base       http.RoundTripper
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := t.resilience
+	if err := r.allow(t.service); err != nil {
+		return nil, err
+	}
+	// Read at the time of the request, so test transports set after the
+	// client was made are used
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	policy := r.Policy(t.service)
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(req.Context(), policy.Timeout)
+		res, err := base.RoundTrip(req.WithContext(ctx))
+		if !retryable(res, err) {
+			// Calls the caller gave up on say nothing about the service
+			if req.Context().Err() == nil {
+				r.record(t.service, failed(res, err))
+			}
+			return withCancel(res, cancel), err
+		}
+		delay := r.backoff(policy, attempt)
+		if after, ok := retryAfter(res, r.now()); ok {
+			delay = after
+		}
+		// A request with a body can only be sent again if it can be read
+		// again. Waiting longer than MaxDelay is left to the caller
+		if attempt >= policy.Retries || delay > policy.MaxDelay || req.Context().Err() != nil ||
+			(req.Body != nil && req.GetBody == nil) {
+			r.record(t.service, true)
+			return withCancel(res, cancel), err
+		}
+		drain(res)
+		cancel()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		slog := log.Debug().Str("service", t.service).Str("url", req.URL.Redacted()).Int("attempt", attempt+1).Dur("delay", delay)
+		if err != nil {
+			slog = slog.Err(err)
+		} else {
+			slog = slog.Int("status", res.StatusCode)
+		}
+		slog.Msg("Request failed, retrying")
+		r.addRetry(t.service)
+		if err := r.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryable is true for network errors and statuses that mean "try again
+// later". Other errors, like a 404 or a 500 from a bug, won't go away
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// failed is true for errors and server errors, whether or not they were
+// retried. Anything else means the service is up
+func failed(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter is how long a Retry-After header asks to wait, in seconds or
+// until a date
+func retryAfter(res *http.Response, now time.Time) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// drain reads and closes a response that won't be used, so the connection
+// can be reused
+func drain(res *http.Response) {
+	if res == nil || res.Body == nil {
+		return
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 1<<16))
+	_ = res.Body.Close()
+}
+
+// withCancel cancels a try's timeout once its response body is closed
+func withCancel(res *http.Response, cancel context.CancelFunc) *http.Response {
+	if res == nil || res.Body == nil {
+		cancel()
+		return res
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// httpClient is the Client's HTTP client for calls to the service
+func (c *Client) httpClient(service string) *http.Client {
+	if c.Resilience == nil {
+		return c.HTTPClient
+	}
+	return c.Resilience.Client(service, c.HTTPClient)
+}
@@ -0,0 +1,180 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestResilience doesn't sleep, it keeps the delays it would have slept for
+func newTestResilience(policies map[string]RetryPolicy) (*Resilience, *[]time.Duration) {
+	r := NewResilience(policies)
+	slept := []time.Duration{}
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return r, &slept
+}
+
+func TestResilienceRetries(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	statuses := []int{503, 429, 200}
+	httpmock.RegisterResponder("GET", "https://api.example.com/films",
+		func(req *http.Request) (*http.Response, error) {
+			res := httpmock.NewStringResponse(statuses[0], "")
+			if statuses[0] == 429 {
+				res.Header.Set("Retry-After", "7")
+			}
+			statuses = statuses[1:]
+			return res, nil
+		})
+
+	r, slept := newTestResilience(nil)
+	res, err := r.Client(ServiceTMDB, nil).Get("https://api.example.com/films")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, 200, res.StatusCode)
+	require.Equal(t, map[string]int{ServiceTMDB: 2}, r.Retries())
+	// Backoff with jitter, then what Retry-After asked for
+	require.Len(t, *slept, 2)
+	require.GreaterOrEqual(t, (*slept)[0], 250*time.Millisecond)
+	require.LessOrEqual(t, (*slept)[0], 500*time.Millisecond)
+	require.Equal(t, 7*time.Second, (*slept)[1])
+
+	// Waiting longer than MaxDelay is given up on, and a 404 isn't retried
+	httpmock.RegisterResponder("GET", "https://api.example.com/busy",
+		func(req *http.Request) (*http.Response, error) {
+			res := httpmock.NewStringResponse(429, "")
+			res.Header.Set("Retry-After", "3600")
+			return res, nil
+		})
+	httpmock.RegisterResponder("GET", "https://api.example.com/missing", httpmock.NewStringResponder(404, ""))
+	for _, path := range []string{"busy", "missing"} {
+		res, err = r.Client(ServicePlex, nil).Get("https://api.example.com/" + path)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	require.Equal(t, map[string]int{ServiceTMDB: 2}, r.Retries())
+}
+
+func TestResilienceBreaker(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://radarr.example.com/api/v3/movie",
+		httpmock.NewStringResponder(503, ""))
+
+	r, _ := newTestResilience(map[string]RetryPolicy{
+		ServiceRadarr: {Retries: -1, BreakAfter: 2, Cooldown: time.Minute},
+	})
+	now := time.Date(2022, 7, 1, 20, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	client := r.Client(ServiceRadarr, nil)
+	for i := 0; i < 2; i++ {
+		res, err := client.Get("https://radarr.example.com/api/v3/movie")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	require.Equal(t, []string{ServiceRadarr}, r.Unavailable())
+
+	// Open, so the call isn't made
+	_, err := client.Get("https://radarr.example.com/api/v3/movie")
+	var open *CircuitOpenError
+	require.ErrorAs(t, err, &open)
+	require.Equal(t, ServiceRadarr, open.Service)
+	require.Equal(t, 2, httpmock.GetTotalCallCount())
+	// Other services carry on
+	require.NoError(t, r.allow(ServiceTMDB))
+
+	// After the cooldown a call is let through, and closes it if it works
+	now = now.Add(time.Minute)
+	httpmock.RegisterResponder("GET", "https://radarr.example.com/api/v3/movie",
+		httpmock.NewStringResponder(200, "[]"))
+	res, err := client.Get("https://radarr.example.com/api/v3/movie")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, []string{}, r.Unavailable())
+
+	// Errors that aren't retried still count against the service
+	httpmock.RegisterResponder("GET", "https://radarr.example.com/api/v3/movie",
+		httpmock.NewStringResponder(500, ""))
+	for i := 0; i < 2; i++ {
+		res, err := client.Get("https://radarr.example.com/api/v3/movie")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+	require.Equal(t, []string{ServiceRadarr}, r.Unavailable())
+}
+
+func TestResilienceDo(t *testing.T) {
+	r, slept := newTestResilience(map[string]RetryPolicy{
+		ServiceLetterboxd: {Retries: 2, BaseDelay: time.Second},
+	})
+	var calls int
+	err := r.Do(context.Background(), ServiceLetterboxd, func(ctx context.Context) error {
+		calls++
+		_, ok := ctx.Deadline()
+		require.True(t, ok)
+		if calls < 3 {
+			return errors.New("oops")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, calls)
+	require.Len(t, *slept, 2)
+	require.Equal(t, map[string]int{ServiceLetterboxd: 2}, r.Retries())
+
+	err = r.Do(context.Background(), ServiceLetterboxd, func(ctx context.Context) error {
+		return errors.New("down")
+	})
+	require.EqualError(t, err, "down")
+
+	// Nil calls straight through
+	var none *Resilience
+	require.NoError(t, none.Do(context.Background(), ServiceLetterboxd, func(ctx context.Context) error { return nil }))
+}
+
+func TestResilienceTMDBRetryAfter(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	searchRes, err := ioutil.ReadFile("testdata/search_movie.json")
+	require.NoError(t, err)
+	limited := true
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/search/movie",
+		func(req *http.Request) (*http.Response, error) {
+			if limited {
+				limited = false
+				res := httpmock.NewStringResponse(429, `{"status_code":25,"status_message":"Your request count is over the allowed limit."}`)
+				res.Header.Set("Retry-After", "25")
+				return res, nil
+			}
+			return httpmock.NewStringResponse(200, string(searchRes)), nil
+		})
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:          "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{DisableCache: true},
+	})
+	require.NoError(t, err)
+	// The wait has to fit in what's left of the request, not just the 10s the
+	// TMDB client would give it
+	c.Resilience.sleep = func(ctx context.Context, d time.Duration) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.Greater(t, time.Until(deadline), d)
+		return nil
+	}
+	results, err := c.TMDB.SearchMovies(context.Background(), "The Handmaiden", 0)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, map[string]int{ServiceTMDB: 1}, c.Resilience.Retries())
+}
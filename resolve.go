@@ -0,0 +1,78 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	tmdb "github.com/cyruzin/golang-tmdb"
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// FilmRef is whatever is known about a film before it is looked up
+type FilmRef struct {
+	IMDBID string
+	TMDBID string
+	Title  string
+	Year   int
+	// Directors help pick between films with the same title
+	Directors []string
+}
+
+func (r FilmRef) String() string {
+	if r.Year == 0 {
+		return r.Title
+	}
+	return fmt.Sprintf("%v (%v)", r.Title, r.Year)
+}
+
+// FilmRefWithLetterboxd is the IDs, title and year of a Letterboxd film
+func FilmRefWithLetterboxd(f *letterboxd.Film) FilmRef {
+	ref := FilmRef{Title: f.Title, Year: f.Year}
+	if f.ExternalIDs != nil {
+		ref.IMDBID = f.ExternalIDs.IMDB
+		ref.TMDBID = f.ExternalIDs.TMDB
+	}
+	return ref
+}
+
+// ResolveFilm looks a film up on TMDB with the best thing known about it. The
+// TMDB ID is a single call, the IMDB ID needs a /find first, and failing both
+// it searches by title and year, returning an UnresolvedError if nothing found
+// is a confident match
+func (c *Client) ResolveFilm(ctx context.Context, ref FilmRef) (*tmdb.MovieDetails, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	slog := log.With().Str("title", ref.Title).Str("imdb", ref.IMDBID).Str("tmdb", ref.TMDBID).Logger()
+	if ref.TMDBID != "" {
+		id, err := strconv.Atoi(ref.TMDBID)
+		if err == nil {
+			m, err := c.TMDB.GetWithTMDBID(ctx, id)
+			if err == nil {
+				return m, nil
+			}
+			slog.Debug().Err(err).Msg("Error looking up by TMDB ID, trying the next way")
+		} else {
+			slog.Debug().Msg("TMDB ID isn't a number, ignoring it")
+		}
+	}
+	if ref.IMDBID != "" {
+		m, err := c.TMDB.GetWithIMDBID(ctx, ref.IMDBID)
+		if err == nil {
+			return m, nil
+		}
+		slog.Debug().Err(err).Msg("Error looking up by IMDB ID, trying the next way")
+	}
+	if ref.Title == "" {
+		return nil, errors.New("nothing to look the film up with")
+	}
+	match, err := c.MatchFilm(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug().Int64("match", match.TMDBID).Float64("confidence", match.Confidence).Msg("Matched film by title")
+	return c.TMDB.GetWithTMDBID(ctx, int(match.TMDBID))
+}
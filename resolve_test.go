@@ -0,0 +1,148 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	tmdb "github.com/cyruzin/golang-tmdb"
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/go-redis/cache/v8"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func newResolveTestClient(t *testing.T, withCache bool) *Client {
+	findRes, err := ioutil.ReadFile("testdata/find_tmdb.json")
+	require.NoError(t, err)
+	movieDetails, err := ioutil.ReadFile("testdata/movie_details.json")
+	require.NoError(t, err)
+	searchRes, err := ioutil.ReadFile("testdata/search_movie.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/find/tt4016934",
+		httpmock.NewStringResponder(200, string(findRes)))
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/movie/290098",
+		httpmock.NewStringResponder(200, string(movieDetails)))
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/movie/999",
+		httpmock.NewStringResponder(404, `{"status_code":34,"status_message":"The resource you requested could not be found."}`))
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/search/movie",
+		httpmock.NewStringResponder(200, string(searchRes)))
+
+	config := ClientConfig{
+		TMDBKey:          "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{DisableCache: true},
+	}
+	if withCache {
+		config.Cache = cache.New(&cache.Options{LocalCache: cache.NewTinyLFU(1000, time.Minute)})
+	}
+	c, err := NewClient(config)
+	require.NoError(t, err)
+	return c
+}
+
+func TestResolveFilm(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c := newResolveTestClient(t, false)
+	ctx := context.Background()
+	calls := func(prefix string) int {
+		var n int
+		for k, v := range httpmock.GetCallCountInfo() {
+			if strings.HasPrefix(k, prefix) {
+				n += v
+			}
+		}
+		return n
+	}
+
+	// The TMDB ID goes straight to the details
+	m, err := c.ResolveFilm(ctx, FilmRef{TMDBID: "290098", IMDBID: "tt4016934"})
+	require.NoError(t, err)
+	require.Equal(t, "The Handmaiden", m.Title)
+	require.Equal(t, 0, calls("GET =~^https://api.themoviedb.org/3/find"))
+
+	// A bad TMDB ID falls back to IMDB
+	m, err = c.ResolveFilm(ctx, FilmRef{TMDBID: "999", IMDBID: "tt4016934"})
+	require.NoError(t, err)
+	require.Equal(t, int64(290098), m.ID)
+	require.Equal(t, 1, calls("GET =~^https://api.themoviedb.org/3/find"))
+
+	// With no IDs, the title and year pick between films of the same name
+	m, err = c.ResolveFilm(ctx, FilmRef{Title: "The Handmaiden", Year: 2017})
+	require.NoError(t, err)
+	require.Equal(t, int64(290098), m.ID)
+	// Too far from either year to trust
+	_, err = c.ResolveFilm(ctx, FilmRef{Title: "The Handmaiden", Year: 2005})
+	var unresolved *UnresolvedError
+	require.ErrorAs(t, err, &unresolved)
+	require.EqualError(t, err, "no confident match on TMDB for The Handmaiden (2005), the closest was The Handmaiden (2016) at 71%")
+	_, err = c.ResolveFilm(ctx, FilmRef{})
+	require.EqualError(t, err, "nothing to look the film up with")
+
+	require.Equal(t, FilmRef{Title: "Cure", Year: 1997, TMDBID: "36095"}, FilmRefWithLetterboxd(&letterboxd.Film{
+		Title: "Cure", Year: 1997, ExternalIDs: &letterboxd.FilmExternalIDs{TMDB: "36095"},
+	}))
+}
+
+func TestTMDBSharedCache(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c := newResolveTestClient(t, true)
+	ctx := context.Background()
+
+	_, err := c.TMDB.GetWithTMDBID(ctx, 290098)
+	require.NoError(t, err)
+	// The TMDB lookup left the IMDB ID pointing at the same entry, so neither
+	// /find nor the details are fetched again
+	m, err := c.TMDB.GetWithIMDBID(ctx, "tt4016934")
+	require.NoError(t, err)
+	require.Equal(t, "The Handmaiden", m.Title)
+	require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	results, err := c.TMDB.SearchMovies(ctx, "The Handmaiden", 0)
+	require.NoError(t, err)
+	require.Equal(t, []*TMDBSearchResult{
+		{ID: 1022, Title: "The Handmaiden", OriginalTitle: "The Handmaiden", ReleaseYear: 1994, Popularity: 0.6},
+		{ID: 290098, Title: "The Handmaiden", OriginalTitle: "아가씨", ReleaseYear: 2016, Popularity: 31.2},
+	}, results)
+	_, err = c.TMDB.SearchMovies(ctx, "the handmaiden", 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, httpmock.GetTotalCallCount())
+
+	// Details cached before the alternative titles were fetched are left alone
+	require.NoError(t, c.Config.Cache.Set(&cache.Item{
+		Ctx:   ctx,
+		Key:   "/letswatch/tmdb/by-tmdb-id/1022",
+		Value: tmdb.MovieDetails{ID: 1022, Title: "Stale"},
+	}))
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/movie/1022",
+		httpmock.NewStringResponder(200, `{"id":1022,"title":"The Handmaiden"}`))
+	m, err = c.TMDB.GetWithTMDBID(ctx, 1022)
+	require.NoError(t, err)
+	require.Equal(t, "The Handmaiden", m.Title)
+	require.Equal(t, 3, httpmock.GetTotalCallCount())
+}
+
+func TestTMDBRefresh(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	c := newResolveTestClient(t, true)
+	ctx := context.Background()
+
+	_, err := c.TMDB.GetWithTMDBID(ctx, 290098)
+	require.NoError(t, err)
+	_, err = c.TMDB.GetWithTMDBID(ctx, 290098)
+	require.NoError(t, err)
+	require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+	// Refreshing skips the cache, by TMDB ID or through the IMDB ID
+	require.NoError(t, c.refreshFilm(ctx, FilmRef{TMDBID: "290098"}))
+	require.Equal(t, 2, httpmock.GetTotalCallCount())
+	require.NoError(t, c.refreshFilm(ctx, FilmRef{IMDBID: "tt4016934"}))
+	require.Equal(t, 3, httpmock.GetTotalCallCount())
+	_, err = c.TMDB.GetWithTMDBID(ctx, 290098)
+	require.NoError(t, err)
+	require.Equal(t, 3, httpmock.GetTotalCallCount())
+}
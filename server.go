@@ -0,0 +1,384 @@
+package letswatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/rs/zerolog/log"
+)
+
+// Server exposes a Client over a JSON HTTP API. See the README for the
+// endpoints and response schema
+type Server struct {
+	client *Client
+	me     *PersonInfo
+	mux    *http.ServeMux
+	// ballotMu stops two votes being cast over each other
+	ballotMu sync.Mutex
+}
+
+// ErrorResponse is returned with any non 2xx status
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// MoviesResponse is returned by the recommend endpoint
+type MoviesResponse struct {
+	Count  int      `json:"count"`
+	Movies []*Movie `json:"movies"`
+}
+
+// PlanFilm is a film that supplement would request
+type PlanFilm struct {
+	Title  string `json:"title"`
+	Year   int    `json:"year,omitempty"`
+	IMDBID string `json:"imdb_id,omitempty"`
+	TMDBID string `json:"tmdb_id,omitempty"`
+}
+
+// PlanResponse is returned by the supplement plan endpoint
+type PlanResponse struct {
+	Backend string      `json:"backend"`
+	Count   int         `json:"count"`
+	Films   []*PlanFilm `json:"films"`
+}
+
+// BallotResponse is the open vote, without how anyone voted
+type BallotResponse struct {
+	Opened     time.Time `json:"opened"`
+	Candidates []*Movie  `json:"candidates"`
+	Voters     []string  `json:"voters"`
+}
+
+// VoteRequest is posted to the vote endpoint. Ranking is candidate numbers,
+// counting from 1, or IMDB or TMDB IDs, favourite first
+type VoteRequest struct {
+	Voter   string   `json:"voter"`
+	Ranking []string `json:"ranking"`
+}
+
+// NewServer returns a Server for the given client, making recommendations for
+// the given person
+func NewServer(c *Client, me *PersonInfo) *Server {
+	s := &Server{
+		client: c,
+		me:     me,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/v1/recommend", s.handleRecommend)
+	s.mux.HandleFunc("/v1/pick", s.handlePick)
+	s.mux.HandleFunc("/v1/films/", s.handleFilm)
+	s.mux.HandleFunc("/v1/supplement/plan", s.handleSupplementPlan)
+	s.mux.HandleFunc("/v1/vote", s.handleVote)
+	s.mux.HandleFunc("/v1/vote/tally", s.handleVoteTally)
+	s.mux.HandleFunc("/radarr/list", s.handleRadarrList)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debug().Str("method", r.Method).Str("path", r.URL.Path).Msg("Request")
+	// Votes are the only thing that can be sent
+	if r.Method != http.MethodGet && !(r.Method == http.MethodPost && r.URL.Path == "/v1/vote") {
+		writeError(w, http.StatusMethodNotAllowed, "only GET is supported, apart from POST /v1/vote")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// validateRemoteJSONLists makes sure JSON lists passed over the API are URLs,
+// so requests can't read files off the server, and that they are on one of the
+// allowed hosts if any are configured
+func (s *Server) validateRemoteJSONLists(sources []string) error {
+	var hosts []string
+	if s.client.Config != nil {
+		hosts = s.client.Config.JSONListHosts
+	}
+	for _, source := range sources {
+		if !isURL(source) {
+			return fmt.Errorf("json-list must be an http(s) URL: %v", source)
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+		u, err := url.Parse(source)
+		if err != nil {
+			return fmt.Errorf("json-list is not a valid URL: %v", source)
+		}
+		if !ContainsString(hosts, u.Hostname()) {
+			return fmt.Errorf("json-list host is not allowed: %v", u.Hostname())
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("Error writing response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, &ErrorResponse{Error: msg})
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// recommendations runs the recommend pipeline with options from the query
+func (s *Server) recommendations(r *http.Request) ([]*Movie, int, error) {
+	q := r.URL.Query()
+	filter, err := NewMovieFilterOptsWithValues(q)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err = filter.ValidateWithPerson(s.me); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	collect, err := NewMovieCollectOptsWithValues(q)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if err = s.validateRemoteJSONLists(collect.JSONLists); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	movies, err := s.client.Recommend(r.Context(), s.me, filter, collect)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return movies, http.StatusOK, nil
+}
+
+func (s *Server) handleRecommend(w http.ResponseWriter, r *http.Request) {
+	movies, status, err := s.recommendations(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, &MoviesResponse{
+		Count:  len(movies),
+		Movies: movies,
+	})
+}
+
+func (s *Server) handlePick(w http.ResponseWriter, r *http.Request) {
+	movies, status, err := s.recommendations(r)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	pick := PickMovie(movies)
+	if pick == nil {
+		writeError(w, http.StatusNotFound, "no films matched")
+		return
+	}
+	s.client.recordHistory(pick.IMDBID, pick.Title, pick.ReleaseYear, HistoryPicked)
+	writeJSON(w, http.StatusOK, pick)
+}
+
+// handleFilm looks up a single film with /v1/films/imdb/<id> or
+// /v1/films/tmdb/<id>
+func (s *Server) handleFilm(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/films/"), "/"), "/")
+	if len(parts) != 2 || parts[1] == "" {
+		writeError(w, http.StatusNotFound, "use /v1/films/imdb/<id> or /v1/films/tmdb/<id>")
+		return
+	}
+	var movie *Movie
+	var err error
+	switch parts[0] {
+	case "imdb":
+		movie, err = s.client.MovieWithIMDBID(r.Context(), parts[1], s.me)
+	case "tmdb":
+		id, cerr := strconv.Atoi(parts[1])
+		if cerr != nil {
+			writeError(w, http.StatusBadRequest, "tmdb id must be a number")
+			return
+		}
+		movie, err = s.client.MovieWithTMDBID(r.Context(), id, s.me)
+	default:
+		writeError(w, http.StatusNotFound, "use /v1/films/imdb/<id> or /v1/films/tmdb/<id>")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, movie)
+}
+
+func (s *Server) handleSupplementPlan(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lists, err := parseListArgs(q["list"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	collect := &MovieCollectOpts{
+		Lists:     lists,
+		JSONLists: q["json-list"],
+	}
+	if len(collect.Lists) == 0 && len(collect.JSONLists) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one list or JSON list is required")
+		return
+	}
+	if err = s.validateRemoteJSONLists(collect.JSONLists); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err = CompileGlobs(q["match-glob"]); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	films, err := s.client.SupplementPlan(r.Context(), s.me, collect, q["match-glob"])
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	backend := RequestBackendRadarr
+	if s.client.Config != nil && s.client.Config.RequestBackend != "" {
+		backend = s.client.Config.RequestBackend
+	}
+	ret := &PlanResponse{
+		Backend: backend,
+		Films:   []*PlanFilm{},
+	}
+	for _, f := range films {
+		ret.Films = append(ret.Films, planFilmWithFilm(f))
+	}
+	ret.Count = len(ret.Films)
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// ballot loads the open vote, writing the error if there isn't one
+func (s *Server) ballot(w http.ResponseWriter) *Ballot {
+	b, err := s.client.Ballot()
+	switch {
+	case err == errNoVote || err == errNoBallotPath:
+		writeError(w, http.StatusNotFound, err.Error())
+		return nil
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	return b
+}
+
+// handleVote shows the open vote with GET, and casts one with POST
+func (s *Server) handleVote(w http.ResponseWriter, r *http.Request) {
+	s.ballotMu.Lock()
+	defer s.ballotMu.Unlock()
+	b := s.ballot(w)
+	if b == nil {
+		return
+	}
+	if r.Method == http.MethodPost {
+		var req VoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid vote: %v", err))
+			return
+		}
+		if err := b.Cast(req.Voter, req.Ranking, time.Now()); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := b.Save(s.client.Config.BallotPath); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, &BallotResponse{
+		Opened:     b.Opened,
+		Candidates: b.Candidates,
+		Voters:     b.Voters(),
+	})
+}
+
+// handleVoteTally counts the open vote, with instant runoff unless method is
+// given
+func (s *Server) handleVoteTally(w http.ResponseWriter, r *http.Request) {
+	s.ballotMu.Lock()
+	b := s.ballot(w)
+	s.ballotMu.Unlock()
+	if b == nil {
+		return
+	}
+	method := r.URL.Query().Get("method")
+	if method == "" {
+		method = TallyInstantRunoff
+	}
+	res, err := b.Tally(method)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func planFilmWithFilm(f *letterboxd.Film) *PlanFilm {
+	p := &PlanFilm{
+		Title: f.Title,
+		Year:  f.Year,
+	}
+	if f.ExternalIDs != nil {
+		p.IMDBID = f.ExternalIDs.IMDB
+		p.TMDBID = f.ExternalIDs.TMDB
+	}
+	return p
+}
+
+// handleRadarrList renders the films on one or more Letterboxd lists in the
+// StevenLu JSON format, so Radarr can poll it as a Custom List. Films can be
+// pruned with a comma separated exclude, such as 'watched,my-streaming'
+func (s *Server) handleRadarrList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lists, err := parseListArgs(q["source"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(lists) == 0 {
+		writeError(w, http.StatusBadRequest, "at least one source is required")
+		return
+	}
+	excludes := []string{}
+	for _, e := range q["exclude"] {
+		excludes = append(excludes, strings.Split(e, ",")...)
+	}
+	popt, err := NewPruneOptsWithExcludes(excludes)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err = CompileGlobs(q["match-glob"]); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	popt.RemoveTitleGlobs = q["match-glob"]
+
+	films, err := s.client.ListFilms(r.Context(), lists)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	films, err = s.client.PruneFilms(r.Context(), films, *popt)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	ret := []RadarrMovie{}
+	for _, f := range films {
+		ret = append(ret, NewRadarrMovieWithFilm(f))
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
@@ -0,0 +1,88 @@
+package letswatch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *Server {
+	c, err := NewClient(ClientConfig{
+		TMDBKey: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+	return NewServer(c, &PersonInfo{
+		LetterboxdUsername: "me",
+		SubscribedTo:       []string{"Shudder"},
+	})
+}
+
+func TestServerErrors(t *testing.T) {
+	s := newTestServer(t)
+	tests := map[string]struct {
+		method string
+		path   string
+		status int
+	}{
+		"health":         {method: "GET", path: "/healthz", status: http.StatusOK},
+		"post":           {method: "POST", path: "/v1/recommend", status: http.StatusMethodNotAllowed},
+		"bad-list":       {method: "GET", path: "/v1/recommend?list=foo", status: http.StatusBadRequest},
+		"bad-runtime":    {method: "GET", path: "/v1/pick?list=foo/bar&max-runtime=long", status: http.StatusBadRequest},
+		"bad-tmdb":       {method: "GET", path: "/v1/films/tmdb/abc", status: http.StatusBadRequest},
+		"bad-film-path":  {method: "GET", path: "/v1/films/letterboxd/abc", status: http.StatusNotFound},
+		"plan-no-lists":  {method: "GET", path: "/v1/supplement/plan", status: http.StatusBadRequest},
+		"radarr-no-src":  {method: "GET", path: "/radarr/list", status: http.StatusBadRequest},
+		"radarr-exclude": {method: "GET", path: "/radarr/list?source=dave/top&exclude=watched,never", status: http.StatusBadRequest},
+		"radarr-glob":    {method: "GET", path: "/radarr/list?source=dave/top&match-glob=%5B", status: http.StatusBadRequest},
+		"plan-bad-glob":  {method: "GET", path: "/v1/supplement/plan?list=dave/top&match-glob=%5B", status: http.StatusBadRequest},
+		"unknown-path":   {method: "GET", path: "/v2/recommend", status: http.StatusNotFound},
+		"bad-bool":       {method: "GET", path: "/v1/recommend?only-not-my-streaming=maybe&list=foo/bar", status: http.StatusBadRequest},
+	}
+	for k, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		require.Equal(t, tt.status, rec.Code, k)
+	}
+}
+
+func TestServerJSONListHosts(t *testing.T) {
+	s := newTestServer(t)
+	require.NoError(t, s.validateRemoteJSONLists([]string{"https://anywhere.example.com/list.json"}))
+	require.EqualError(t, s.validateRemoteJSONLists([]string{"/etc/passwd"}), "json-list must be an http(s) URL: /etc/passwd")
+
+	s.client.Config.JSONListHosts = []string{"mdblist.example.com"}
+	require.NoError(t, s.validateRemoteJSONLists([]string{"https://mdblist.example.com/lists/top250/json"}))
+	require.EqualError(t, s.validateRemoteJSONLists([]string{"http://169.254.169.254/latest"}), "json-list host is not allowed: 169.254.169.254")
+}
+
+func TestServerFilmWithTMDBID(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	movieDetails, err := ioutil.ReadFile("testdata/movie_details.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "https://api.themoviedb.org/3/movie/290098",
+		httpmock.NewStringResponder(200, string(movieDetails)))
+	httpmock.RegisterResponder("GET", "https://api.themoviedb.org/3/movie/290098/watch/providers",
+		httpmock.NewStringResponder(200, `{"id":290098,"results":{"US":{"flatrate":[{"provider_name":"Shudder"},{"provider_name":"Hulu"}]}}}`))
+
+	s := newTestServer(t)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/films/tmdb/290098", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got Movie
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, "The Handmaiden", got.Title)
+	require.Equal(t, "290098", got.TMDBID)
+	require.Equal(t, []string{"Shudder"}, got.StreamingOnMy)
+}
@@ -0,0 +1,178 @@
+package letswatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Formats the shortlist can be exported in
+const (
+	ShortlistFormatLetterboxdCSV = "letterboxd-csv"
+	ShortlistFormatJSON          = "json"
+)
+
+// shortlistID identifies a film on the shortlist. Films without an IMDB ID
+// fall back to their TMDB one
+func shortlistID(m *Movie) string {
+	if m.IMDBID != "" {
+		return m.IMDBID
+	}
+	return "tmdb:" + m.TMDBID
+}
+
+// shortlistIndex returns where the film with the given IMDB or TMDB ID is on
+// the shortlist, or -1 if it isn't
+func shortlistIndex(movies []*Movie, id string) int {
+	for i, m := range movies {
+		if id != "" && (m.IMDBID == id || m.TMDBID == id || shortlistID(m) == id) {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveMovie returns the movies with the one at from moved to index to. Both
+// must be in range
+func moveMovie(movies []*Movie, from, to int) []*Movie {
+	ret := make([]*Movie, 0, len(movies))
+	moving := movies[from]
+	for i, m := range movies {
+		if i != from {
+			ret = append(ret, m)
+		}
+	}
+	ret = append(ret[:to], append([]*Movie{moving}, ret[to:]...)...)
+	return ret
+}
+
+// Shortlist returns the shortlisted films, in order
+func (c *Client) Shortlist() ([]*Movie, error) {
+	if c.Store == nil {
+		return nil, errNoStore
+	}
+	return c.Store.Shortlist()
+}
+
+// SaveShortlist replaces the shortlist
+func (c *Client) SaveShortlist(movies []*Movie) error {
+	if c.Store == nil {
+		return errNoStore
+	}
+	return c.Store.SetShortlist(movies)
+}
+
+// ShortlistAdd looks up each IMDB ID on TMDB and adds the film to the end of
+// the shortlist. Films already on it stay where they are
+func (c *Client) ShortlistAdd(ctx context.Context, imdbIDs ...string) ([]*Movie, error) {
+	movies, err := c.Shortlist()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, id := range imdbIDs {
+		if shortlistIndex(movies, id) >= 0 {
+			log.Debug().Str("imdb", id).Msg("Already shortlisted")
+			continue
+		}
+		m, err := c.TMDB.GetWithIMDBID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("looking up %v: %w", id, err)
+		}
+		movie := NewMovieWithTMDB(m)
+		c.FillDetails(ctx, movie)
+		movies = append(movies, movie)
+	}
+	return movies, c.SaveShortlist(movies)
+}
+
+// ShortlistRemove takes the films with the given IMDB or TMDB IDs off the
+// shortlist
+func (c *Client) ShortlistRemove(ids ...string) ([]*Movie, error) {
+	movies, err := c.Shortlist()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		i := shortlistIndex(movies, id)
+		if i < 0 {
+			return nil, fmt.Errorf("film is not on the shortlist: %v", id)
+		}
+		movies = append(movies[:i], movies[i+1:]...)
+	}
+	return movies, c.SaveShortlist(movies)
+}
+
+// ShortlistMove moves a film to a new position on the shortlist, counting
+// from 1
+func (c *Client) ShortlistMove(id string, position int) ([]*Movie, error) {
+	movies, err := c.Shortlist()
+	if err != nil {
+		return nil, err
+	}
+	i := shortlistIndex(movies, id)
+	if i < 0 {
+		return nil, fmt.Errorf("film is not on the shortlist: %v", id)
+	}
+	if position < 1 || position > len(movies) {
+		return nil, fmt.Errorf("position must be between 1 and %v", len(movies))
+	}
+	movies = moveMovie(movies, i, position-1)
+	return movies, c.SaveShortlist(movies)
+}
+
+// WriteShortlist writes the films as a Letterboxd import CSV, or as JSON in
+// the same shape the API returns
+func WriteShortlist(w io.Writer, movies []*Movie, format string) error {
+	switch format {
+	case ShortlistFormatLetterboxdCSV:
+		csvW := NewLetterboxdCSVWriter(w, LetterboxdCSVOpts{})
+		for _, m := range movies {
+			if err := csvW.Write(m); err != nil {
+				return err
+			}
+		}
+		return csvW.Flush()
+	case ShortlistFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&MoviesResponse{
+			Count:  len(movies),
+			Movies: movies,
+		})
+	default:
+		return fmt.Errorf("unknown shortlist format: %v", format)
+	}
+}
+
+// ShortlistToPlexPlaylist puts the shortlisted films that are in the Plex
+// library on a playlist, in shortlist order. It returns how many were found
+func (c *Client) ShortlistToPlexPlaylist(ctx context.Context, name string, mode PlexListMode) (int, error) {
+	if c.Plex == nil {
+		return 0, errors.New("plex must be configured to send the shortlist to a playlist")
+	}
+	movies, err := c.Shortlist()
+	if err != nil {
+		return 0, err
+	}
+	items := []*PlexMovie{}
+	for _, m := range movies {
+		pm, err := c.Plex.FindMovie(ctx, m.Title, m.ReleaseYear)
+		if err != nil {
+			log.Warn().Err(err).Str("title", m.Title).Msg("Error finding film in plex")
+			continue
+		}
+		if pm == nil {
+			log.Info().Str("title", m.Title).Msg("Not in plex, skipping")
+			continue
+		}
+		items = append(items, pm)
+	}
+	return len(items), c.Plex.UpdatePlaylist(ctx, name, items, mode)
+}
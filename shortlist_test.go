@@ -0,0 +1,102 @@
+package letswatch
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveMovie(t *testing.T) {
+	movies := facetTestMovies()
+	require.Equal(t, []string{"Alien", "The Handmaiden", "Audition", "Cure"}, movieTitles(moveMovie(movies, 2, 0)))
+	require.Equal(t, []string{"Audition", "Alien", "Cure", "The Handmaiden"}, movieTitles(moveMovie(movies, 0, 3)))
+	require.Equal(t, []string{"The Handmaiden", "Audition", "Alien", "Cure"}, movieTitles(movies))
+	require.Equal(t, 1, shortlistIndex(movies, "tt0235198"))
+	require.Equal(t, -1, shortlistIndex(movies, "tt0000001"))
+	require.Equal(t, "tmdb:11075", shortlistID(&Movie{TMDBID: "11075"}))
+}
+
+func TestShortlist(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+	findRes, err := ioutil.ReadFile("testdata/find_tmdb.json")
+	require.NoError(t, err)
+	movieDetails, err := ioutil.ReadFile("testdata/movie_details.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "=~^https://api.themoviedb.org/3/find/tt4016934",
+		httpmock.NewStringResponder(200, string(findRes)))
+	httpmock.RegisterResponder("GET", "https://api.themoviedb.org/3/movie/290098",
+		httpmock.NewStringResponder(200, string(movieDetails)))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:          "foo",
+		StorePath:        filepath.Join(t.TempDir(), "letswatch.db"),
+		LetterboxdConfig: &letterboxd.ClientConfig{DisableCache: true},
+	})
+	require.NoError(t, err)
+	c.Requester = nil
+
+	movies, err := c.Shortlist()
+	require.NoError(t, err)
+	require.Empty(t, movies)
+
+	require.NoError(t, c.SaveShortlist([]*Movie{
+		{Title: "Audition", ReleaseYear: 1999, IMDBID: "tt0235198", TMDBID: "11075"},
+		{Title: "Cure", ReleaseYear: 1997, IMDBID: "tt0123948", TMDBID: "36095"},
+	}))
+	movies, err = c.ShortlistAdd(context.Background(), "tt4016934", "tt0235198")
+	require.NoError(t, err)
+	require.Equal(t, []string{"Audition", "Cure", "The Handmaiden"}, movieTitles(movies))
+	require.Equal(t, "290098", movies[2].TMDBID)
+
+	// Moves are by IMDB or TMDB ID
+	movies, err = c.ShortlistMove("290098", 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"The Handmaiden", "Audition", "Cure"}, movieTitles(movies))
+	_, err = c.ShortlistMove("tt0235198", 4)
+	require.EqualError(t, err, "position must be between 1 and 3")
+	_, err = c.ShortlistMove("tt0000001", 1)
+	require.EqualError(t, err, "film is not on the shortlist: tt0000001")
+
+	movies, err = c.ShortlistRemove("tt0235198")
+	require.NoError(t, err)
+	require.Equal(t, []string{"The Handmaiden", "Cure"}, movieTitles(movies))
+	_, err = c.ShortlistRemove("tt0235198")
+	require.Error(t, err)
+
+	// It all stuck
+	movies, err = c.Shortlist()
+	require.NoError(t, err)
+	require.Equal(t, []string{"The Handmaiden", "Cure"}, movieTitles(movies))
+	require.Equal(t, 2016, movies[0].ReleaseYear)
+
+	_, err = c.ShortlistToPlexPlaylist(context.Background(), "Tonight", PlexListReplace)
+	require.Error(t, err)
+	_, err = (&Client{}).Shortlist()
+	require.Equal(t, errNoStore, err)
+}
+
+func TestWriteShortlist(t *testing.T) {
+	movies := []*Movie{
+		{Title: "The Handmaiden", ReleaseYear: 2016, IMDBID: "tt4016934", TMDBID: "290098"},
+		{Title: "Cure", ReleaseYear: 1997, IMDBID: "tt0123948", TMDBID: "36095"},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, WriteShortlist(&buf, movies, ShortlistFormatLetterboxdCSV))
+	require.Equal(t, "imdbID,tmdbID,Title,Year\ntt4016934,290098,The Handmaiden,2016\ntt0123948,36095,Cure,1997\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteShortlist(&buf, movies, ShortlistFormatJSON))
+	require.JSONEq(t, `{"count":2,"movies":[
+		{"title":"The Handmaiden","release_year":2016,"imdb_id":"tt4016934","tmdb_id":"290098"},
+		{"title":"Cure","release_year":1997,"imdb_id":"tt0123948","tmdb_id":"36095"}
+	]}`, buf.String())
+
+	require.EqualError(t, WriteShortlist(&buf, movies, "xml"), "unknown shortlist format: xml")
+}
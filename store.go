@@ -0,0 +1,342 @@
+package letswatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Things that can happen to a film, as recorded in the Store
+const (
+	HistoryRecommended   = "recommended"
+	HistoryPicked        = "picked"
+	HistoryAdded         = "added"
+	HistoryNotInterested = "not-interested"
+	HistoryBracketWon    = "bracket-won"
+	HistoryBracketLost   = "bracket-lost"
+)
+
+// FilmHistory is everything the Store knows about a single film
+type FilmHistory struct {
+	// ID is the IMDB ID
+	ID    string `json:"id" yaml:"id"`
+	Title string `json:"title,omitempty" yaml:"title,omitempty"`
+	Year  int    `json:"year,omitempty" yaml:"year,omitempty"`
+	// Events holds the first time each event happened
+	Events map[string]time.Time `json:"events" yaml:"events"`
+	// Dismissed films are hidden from recommendations, until DismissedUntil
+	// if it is set, or forever if not
+	Dismissed      bool      `json:"dismissed,omitempty" yaml:"dismissed,omitempty"`
+	DismissedUntil time.Time `json:"dismissed_until,omitempty" yaml:"dismissed_until,omitempty"`
+}
+
+// IsDismissed returns true if the film is dismissed at the given time
+func (h *FilmHistory) IsDismissed(now time.Time) bool {
+	if h == nil || !h.Dismissed {
+		return false
+	}
+	return h.DismissedUntil.IsZero() || now.Before(h.DismissedUntil)
+}
+
+// Has returns true if the event has ever happened to the film
+func (h *FilmHistory) Has(event string) bool {
+	if h == nil {
+		return false
+	}
+	_, ok := h.Events[event]
+	return ok
+}
+
+// Store keeps the history of films between runs
+type Store interface {
+	// Record notes that event happened to the film now. If it has happened
+	// before, the original time is kept
+	Record(id, title string, year int, event string) error
+	// Get returns the history of a single film, or nil if it has none
+	Get(id string) (*FilmHistory, error)
+	// List returns every film with history, most recently changed first
+	List() ([]*FilmHistory, error)
+	// Dismiss hides the film until the given time, or forever if it is zero
+	Dismiss(id, title string, year int, until time.Time) error
+	// Undismiss brings back a dismissed film
+	Undismiss(id string) error
+	// Shortlist returns the shortlisted films, in order
+	Shortlist() ([]*Movie, error)
+	// SetShortlist replaces the shortlist
+	SetShortlist(movies []*Movie) error
+}
+
+var (
+	boltFilmsBucket     = []byte("films")
+	boltShortlistBucket = []byte("shortlist")
+	boltShortlistKey    = []byte("movies")
+)
+
+// BoltStore is a Store backed by a bbolt database. The database is only held
+// open for each operation, so the daemon, serve and one off commands can
+// share it. Nothing is created until something is recorded
+type BoltStore struct {
+	path string
+	now  func() time.Time
+}
+
+// NewBoltStore returns a Store using the bbolt database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, errors.New("state store path is required")
+	}
+	return &BoltStore{
+		path: path,
+		now:  time.Now,
+	}, nil
+}
+
+// open opens the database. Read only opens share the lock with each other,
+// and don't create a database that doesn't exist yet, returning nil instead
+func (s *BoltStore) open(readOnly bool) (*bolt.DB, error) {
+	if readOnly {
+		if _, err := os.Stat(s.path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("opening state store %v: %w", s.path, err)
+	}
+	return db, nil
+}
+
+func (s *BoltStore) update(fn func(*bolt.Bucket) error) error {
+	return s.updateBucket(boltFilmsBucket, fn)
+}
+
+func (s *BoltStore) view(fn func(*bolt.Bucket) error) error {
+	return s.viewBucket(boltFilmsBucket, fn)
+}
+
+func (s *BoltStore) updateBucket(name []byte, fn func(*bolt.Bucket) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+func (s *BoltStore) viewBucket(name []byte, fn func(*bolt.Bucket) error) error {
+	db, err := s.open(true)
+	if err != nil || db == nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(name)
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+func getFilmHistory(b *bolt.Bucket, id string) (*FilmHistory, error) {
+	data := b.Get([]byte(id))
+	if data == nil {
+		return nil, nil
+	}
+	h := &FilmHistory{}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// modify runs fn on the history of a film, creating it if needed, and saves
+// the result
+func (s *BoltStore) modify(id, title string, year int, fn func(*FilmHistory) error) error {
+	if id == "" {
+		return errors.New("film id is required")
+	}
+	return s.update(func(b *bolt.Bucket) error {
+		h, err := getFilmHistory(b, id)
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			h = &FilmHistory{ID: id}
+		}
+		if h.Events == nil {
+			h.Events = map[string]time.Time{}
+		}
+		if title != "" {
+			h.Title = title
+		}
+		if year > 0 {
+			h.Year = year
+		}
+		if err = fn(h); err != nil {
+			return err
+		}
+		data, err := json.Marshal(h)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+}
+
+func (s *BoltStore) Record(id, title string, year int, event string) error {
+	return s.modify(id, title, year, func(h *FilmHistory) error {
+		if _, ok := h.Events[event]; !ok {
+			h.Events[event] = s.now()
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Dismiss(id, title string, year int, until time.Time) error {
+	return s.modify(id, title, year, func(h *FilmHistory) error {
+		h.Dismissed = true
+		h.DismissedUntil = until
+		// Snoozing isn't the same as not being interested
+		if until.IsZero() {
+			if _, ok := h.Events[HistoryNotInterested]; !ok {
+				h.Events[HistoryNotInterested] = s.now()
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Undismiss(id string) error {
+	return s.modify(id, "", 0, func(h *FilmHistory) error {
+		if !h.Dismissed {
+			return fmt.Errorf("film is not dismissed: %v", id)
+		}
+		h.Dismissed = false
+		h.DismissedUntil = time.Time{}
+		delete(h.Events, HistoryNotInterested)
+		return nil
+	})
+}
+
+func (s *BoltStore) Get(id string) (*FilmHistory, error) {
+	var ret *FilmHistory
+	err := s.view(func(b *bolt.Bucket) error {
+		var err error
+		ret, err = getFilmHistory(b, id)
+		return err
+	})
+	return ret, err
+}
+
+func (s *BoltStore) List() ([]*FilmHistory, error) {
+	ret := []*FilmHistory{}
+	err := s.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			h := &FilmHistory{}
+			if err := json.Unmarshal(v, h); err != nil {
+				return err
+			}
+			ret = append(ret, h)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].lastEvent().After(ret[j].lastEvent())
+	})
+	return ret, nil
+}
+
+func (s *BoltStore) Shortlist() ([]*Movie, error) {
+	ret := []*Movie{}
+	err := s.viewBucket(boltShortlistBucket, func(b *bolt.Bucket) error {
+		data := b.Get(boltShortlistKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &ret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *BoltStore) SetShortlist(movies []*Movie) error {
+	if movies == nil {
+		movies = []*Movie{}
+	}
+	data, err := json.Marshal(movies)
+	if err != nil {
+		return err
+	}
+	return s.updateBucket(boltShortlistBucket, func(b *bolt.Bucket) error {
+		return b.Put(boltShortlistKey, data)
+	})
+}
+
+func (h *FilmHistory) lastEvent() time.Time {
+	var ret time.Time
+	for _, t := range h.Events {
+		if t.After(ret) {
+			ret = t
+		}
+	}
+	return ret
+}
+
+// RecordRecommended records the movies as recommended, for consumers that stop
+// StreamRecommendations early. Films already recorded keep their first time
+func (c *Client) RecordRecommended(movies ...*Movie) {
+	for _, m := range movies {
+		c.recordHistory(m.IMDBID, m.Title, m.ReleaseYear, HistoryRecommended)
+	}
+}
+
+// History returns what happened to the given films, or to every film the
+// Store knows about if none are given. Films with no history are left out
+func (c *Client) History(imdbIDs ...string) ([]*FilmHistory, error) {
+	if c.Store == nil {
+		return nil, errNoStore
+	}
+	if len(imdbIDs) == 0 {
+		return c.Store.List()
+	}
+	ret := []*FilmHistory{}
+	for _, id := range imdbIDs {
+		h, err := c.Store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			ret = append(ret, h)
+		}
+	}
+	return ret, nil
+}
+
+// recordHistory records an event in the Store, if there is one. Errors are only
+// logged, as history is never worth failing a run over
+func (c *Client) recordHistory(id, title string, year int, event string) {
+	if c.Store == nil || id == "" {
+		return
+	}
+	if err := c.Store.Record(id, title, year, event); err != nil {
+		log.Warn().Err(err).Str("id", id).Str("event", event).Msg("Error recording history")
+	}
+}
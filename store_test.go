@@ -0,0 +1,80 @@
+package letswatch
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "letswatch.db")
+	s, err := NewBoltStore(path)
+	require.NoError(t, err)
+	now := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	// Nothing is created until something is recorded
+	got, err := s.Get("tt4016934")
+	require.NoError(t, err)
+	require.Nil(t, got)
+	require.False(t, got.Has(HistoryRecommended))
+	require.NoFileExists(t, path)
+
+	require.NoError(t, s.Record("tt4016934", "The Handmaiden", 2016, HistoryRecommended))
+	require.FileExists(t, path)
+	first := now
+	now = now.Add(time.Hour)
+	// Recording again keeps the first time
+	require.NoError(t, s.Record("tt4016934", "", 0, HistoryRecommended))
+	require.NoError(t, s.Record("tt4016934", "", 0, HistoryPicked))
+	now = now.Add(time.Hour)
+	require.NoError(t, s.Record("tt0235198", "Audition", 1999, HistoryAdded))
+
+	got, err = s.Get("tt4016934")
+	require.NoError(t, err)
+	require.Equal(t, &FilmHistory{
+		ID:    "tt4016934",
+		Title: "The Handmaiden",
+		Year:  2016,
+		Events: map[string]time.Time{
+			HistoryRecommended: first,
+			HistoryPicked:      first.Add(time.Hour),
+		},
+	}, got)
+	require.True(t, got.Has(HistoryPicked))
+	require.False(t, got.Has(HistoryNotInterested))
+
+	all, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Equal(t, "tt0235198", all[0].ID)
+
+	require.EqualError(t, s.Record("", "Nope", 0, HistoryPicked), "film id is required")
+}
+
+func TestBoltStoreShared(t *testing.T) {
+	// Like the daemon and the UI, two stores on one database take turns
+	// without waiting on each other's lock
+	path := filepath.Join(t.TempDir(), "letswatch.db")
+	a, err := NewBoltStore(path)
+	require.NoError(t, err)
+	b, err := NewBoltStore(path)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, a.Record("tt4016934", "The Handmaiden", 2016, HistoryRecommended))
+	require.NoError(t, b.Dismiss("tt0235198", "Audition", 1999, time.Time{}))
+	got, err := b.Get("tt4016934")
+	require.NoError(t, err)
+	require.True(t, got.Has(HistoryRecommended))
+	got, err = a.Get("tt0235198")
+	require.NoError(t, err)
+	require.True(t, got.IsDismissed(time.Now()))
+	require.NoError(t, a.SetShortlist([]*Movie{{Title: "Audition", IMDBID: "tt0235198"}}))
+	shortlist, err := b.Shortlist()
+	require.NoError(t, err)
+	require.Len(t, shortlist, 1)
+	require.Less(t, time.Since(start), time.Second)
+}
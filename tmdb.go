@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
@@ -13,9 +15,38 @@ import (
 
 type TMDBService interface {
 	GetWithIMDBID(context.Context, string) (*tmdb.MovieDetails, error)
+	GetWithTMDBID(context.Context, int) (*tmdb.MovieDetails, error)
+	// Refresh looks up a film's details even if they are cached, caching
+	// them again
+	Refresh(context.Context, int) (*tmdb.MovieDetails, error)
+	SearchMovies(ctx context.Context, title string, year int) ([]*TMDBSearchResult, error)
 	GetStreamingChannels(id int) ([]string, error)
 }
 
+// TMDBSearchResult is a film found by SearchMovies
+type TMDBSearchResult struct {
+	ID            int64   `json:"id"`
+	Title         string  `json:"title"`
+	OriginalTitle string  `json:"original_title,omitempty"`
+	ReleaseYear   int     `json:"release_year,omitempty"`
+	Popularity    float32 `json:"popularity,omitempty"`
+}
+
+// Cache keys. Film details are only kept under the TMDB ID, other ways of
+// finding a film point at that. The details key is versioned, so details
+// cached before the alternative titles and keywords were fetched aren't used
+func tmdbDetailsKey(id int) string {
+	return fmt.Sprintf("/letswatch/tmdb/v3/by-tmdb-id/%v", id)
+}
+
+func tmdbIMDBAliasKey(imdbID string) string {
+	return fmt.Sprintf("/letswatch/tmdb/imdb-to-tmdb/%v", imdbID)
+}
+
+func tmdbSearchKey(title string, year int) string {
+	return fmt.Sprintf("/letswatch/tmdb/search/%v/%v", year, strings.ToLower(title))
+}
+
 type TMDBServiceOp struct {
 	client     *Client
 	tmdbClient *tmdb.Client
@@ -64,29 +95,42 @@ func (svc *TMDBServiceOp) NewTMDBClientWithCache() (*TMDBClientWithCache, error)
 }
 */
 
-func (t *TMDBServiceOp) GetWithIMDBID(ctx context.Context, imdbID string) (*tmdb.MovieDetails, error) {
-	key := fmt.Sprintf("/letswatch/tmdb/by-imdb-id/%s", imdbID)
-	if ctx == nil {
-		ctx = context.Background()
+// cacheGet fills v from the cache, returning false if it isn't there
+func (t *TMDBServiceOp) cacheGet(ctx context.Context, key string, v interface{}) bool {
+	if t.client.Cache == nil {
+		return false
 	}
-	var movie *tmdb.MovieDetails
+	if err := t.client.Cache.Get(ctx, key, v); err != nil {
+		log.WithError(err).WithField("key", key).Debug("TMDB Entry NOT in cache")
+		return false
+	}
+	log.WithField("key", key).Debug("Found page in cache")
+	return true
+}
 
-	var inCache bool
-
-	if t.client.Cache != nil {
-		log.WithFields(log.Fields{
-			"key": key,
-			"ctx": ctx,
-		}).Debug("Using cache for lookup")
-		if err := t.client.Cache.Get(ctx, key, &movie); err == nil {
-			log.WithField("key", key).Debug("Found page in cache")
-			inCache = true
-		} else {
-			log.WithError(err).WithField("key", key).Debug("TMDB Entry NOT in cache")
-		}
+func (t *TMDBServiceOp) cacheSet(ctx context.Context, key string, v interface{}) {
+	if t.client.Cache == nil {
+		return
+	}
+	if err := t.client.Cache.Set(&cache.Item{
+		Ctx:   ctx,
+		Key:   key,
+		Value: v,
+		TTL:   time.Hour * 24,
+	}); err != nil {
+		log.WithError(err).Warn("Error Writing TMDB Film to Cache")
+	}
+}
 
+// GetWithIMDBID finds the film's TMDB ID, then looks it up with
+// GetWithTMDBID. The ID is cached, so a film looked up either way before
+// doesn't need the /find call
+func (t *TMDBServiceOp) GetWithIMDBID(ctx context.Context, imdbID string) (*tmdb.MovieDetails, error) {
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	if !inCache {
+	var id int64
+	if !t.cacheGet(ctx, tmdbIMDBAliasKey(imdbID), &id) {
 		options := map[string]string{}
 		options["external_source"] = "imdb_id"
 		search, err := t.tmdbClient.GetFindByID(imdbID, options)
@@ -101,26 +145,80 @@ func (t *TMDBServiceOp) GetWithIMDBID(ctx context.Context, imdbID string) (*tmdb
 				"count":   len(search.MovieResults),
 			}).Warn("Found more than one movie, using the first one")
 		}
-		thing := search.MovieResults[0]
+		id = search.MovieResults[0].ID
+		t.cacheSet(ctx, tmdbIMDBAliasKey(imdbID), id)
+	}
+	return t.GetWithTMDBID(ctx, int(id))
+}
 
-		options = map[string]string{}
-		options["append_to_response"] = "credits"
-		movie, err = t.tmdbClient.GetMovieDetails(int(thing.ID), options)
-		if err != nil {
-			return nil, err
-		}
-		if t.client.Cache != nil {
-			if err := t.client.Cache.Set(&cache.Item{
-				Ctx:   ctx,
-				Key:   key,
-				Value: movie,
-				TTL:   time.Hour * 24,
-			}); err != nil {
-				log.WithError(err).Warn("Error Writing TMDB Film to Cache")
+// GetWithTMDBID looks up a film's details, with the credits, alternative
+// titles and keywords
+func (t *TMDBServiceOp) GetWithTMDBID(ctx context.Context, id int) (*tmdb.MovieDetails, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var movie *tmdb.MovieDetails
+	if t.cacheGet(ctx, tmdbDetailsKey(id), &movie) {
+		return movie, nil
+	}
+	return t.Refresh(ctx, id)
+}
+
+// Refresh fetches a film's details from TMDB, skipping the cache, and caches
+// what it gets
+func (t *TMDBServiceOp) Refresh(ctx context.Context, id int) (*tmdb.MovieDetails, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	options := map[string]string{}
+	options["append_to_response"] = "credits,alternative_titles,keywords"
+	movie, err := t.tmdbClient.GetMovieDetails(id, options)
+	if err != nil {
+		return nil, err
+	}
+	t.cacheSet(ctx, tmdbDetailsKey(id), movie)
+	if movie.IMDbID != "" {
+		t.cacheSet(ctx, tmdbIMDBAliasKey(movie.IMDbID), movie.ID)
+	}
+	return movie, nil
+}
+
+// SearchMovies searches TMDB for films with the title, released in year if
+// it isn't 0
+func (t *TMDBServiceOp) SearchMovies(ctx context.Context, title string, year int) ([]*TMDBSearchResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var ret []*TMDBSearchResult
+	key := tmdbSearchKey(title, year)
+	if t.cacheGet(ctx, key, &ret) {
+		return ret, nil
+	}
+	options := map[string]string{}
+	if year > 0 {
+		options["year"] = strconv.Itoa(year)
+	}
+	search, err := t.tmdbClient.GetSearchMovies(title, options)
+	if err != nil {
+		return nil, err
+	}
+	ret = []*TMDBSearchResult{}
+	if search.SearchMoviesResults != nil {
+		for _, r := range search.Results {
+			res := &TMDBSearchResult{
+				ID:            r.ID,
+				Title:         r.Title,
+				OriginalTitle: r.OriginalTitle,
+				Popularity:    r.Popularity,
+			}
+			if len(r.ReleaseDate) >= 4 {
+				res.ReleaseYear, _ = strconv.Atoi(r.ReleaseDate[:4])
 			}
+			ret = append(ret, res)
 		}
 	}
-	return movie, nil
+	t.cacheSet(ctx, key, ret)
+	return ret, nil
 }
 
 func (svc *TMDBServiceOp) GetStreamingChannels(id int) ([]string, error) {
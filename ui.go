@@ -1,56 +1,434 @@
 package letswatch
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/rs/zerolog"
 )
 
-var docStyle = lipgloss.NewStyle().Margin(1, 2)
+// How long the snooze key hides a film for
+const uiSnooze = 90 * 24 * time.Hour
+
+// uiKeyMap holds the keys for actions on the selected film. They are chosen
+// to stay clear of the list's own navigation keys
+type uiKeyMap struct {
+	radarr         key.Binding
+	openIMDB       key.Binding
+	openLetterboxd key.Binding
+	openTMDB       key.Binding
+	copyLink       key.Binding
+	dismiss        key.Binding
+	snooze         key.Binding
+	shortlist      key.Binding
+	mark           key.Binding
+	showShortlist  key.Binding
+	moveUp         key.Binding
+	moveDown       key.Binding
+	sort           key.Binding
+	facets         key.Binding
+	bracket        key.Binding
+	// Only used while the facet pane is open
+	facetUp     key.Binding
+	facetDown   key.Binding
+	facetToggle key.Binding
+	facetClear  key.Binding
+	facetClose  key.Binding
+}
+
+func newUIKeyMap() *uiKeyMap {
+	return &uiKeyMap{
+		radarr: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "add to radarr"),
+		),
+		openIMDB: key.NewBinding(
+			key.WithKeys("I"),
+			key.WithHelp("I", "open imdb"),
+		),
+		openLetterboxd: key.NewBinding(
+			key.WithKeys("L"),
+			key.WithHelp("L", "open letterboxd"),
+		),
+		openTMDB: key.NewBinding(
+			key.WithKeys("T"),
+			key.WithHelp("T", "open tmdb"),
+		),
+		copyLink: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "copy link"),
+		),
+		dismiss: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "not interested"),
+		),
+		snooze: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "snooze 90d"),
+		),
+		shortlist: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "shortlist"),
+		),
+		mark: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "select"),
+		),
+		showShortlist: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "show shortlist"),
+		),
+		moveUp: key.NewBinding(
+			key.WithKeys("K"),
+			key.WithHelp("K", "move up shortlist"),
+		),
+		moveDown: key.NewBinding(
+			key.WithKeys("J"),
+			key.WithHelp("J", "move down shortlist"),
+		),
+		sort: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "sort order"),
+		),
+		facets: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "facets"),
+		),
+		bracket: key.NewBinding(
+			key.WithKeys("B"),
+			key.WithHelp("B", "bracket"),
+		),
+		facetUp: key.NewBinding(
+			key.WithKeys("up", "k"),
+			key.WithHelp("↑/k", "up"),
+		),
+		facetDown: key.NewBinding(
+			key.WithKeys("down", "j"),
+			key.WithHelp("↓/j", "down"),
+		),
+		facetToggle: key.NewBinding(
+			key.WithKeys(" ", "enter"),
+			key.WithHelp("space", "toggle"),
+		),
+		facetClear: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "clear facets"),
+		),
+		facetClose: key.NewBinding(
+			key.WithKeys("F", "esc"),
+			key.WithHelp("F/esc", "close facets"),
+		),
+	}
+}
+
+func (k *uiKeyMap) shortHelp() []key.Binding {
+	return []key.Binding{k.radarr, k.mark, k.shortlist, k.showShortlist, k.dismiss, k.sort, k.facets}
+}
+
+func (k *uiKeyMap) fullHelp() []key.Binding {
+	return []key.Binding{
+		k.radarr, k.openIMDB, k.openLetterboxd, k.openTMDB, k.copyLink, k.dismiss, k.snooze,
+		k.mark, k.shortlist, k.showShortlist, k.moveUp, k.moveDown,
+		k.sort, k.facets, k.facetToggle, k.facetClear, k.bracket,
+	}
+}
+
+var (
+	docStyle         = lipgloss.NewStyle().Margin(1, 2)
+	detailStyle      = lipgloss.NewStyle().Padding(0, 2).BorderStyle(lipgloss.NormalBorder()).BorderLeft(true)
+	detailTitleStyle = lipgloss.NewStyle().Bold(true)
+	detailLabelStyle = lipgloss.NewStyle().Faint(true)
+	myStreamingStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("42"))
+	statusStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	statusErrStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	progressStyle    = lipgloss.NewStyle().Faint(true)
+	facetCursorStyle = lipgloss.NewStyle().Reverse(true)
+)
 
 type MovieItem struct {
-	movie *Movie
+	movie       *Movie
+	shortlisted bool
+	marked      bool
+	// position on the shortlist, when showing it
+	position int
 }
 
 func (i MovieItem) Title() string {
-	return fmt.Sprintf("%v (%v)", i.movie.Title, i.movie.ReleaseYear)
+	title := fmt.Sprintf("%v (%v)", i.movie.Title, i.movie.ReleaseYear)
+	if i.shortlisted {
+		title = "★ " + title
+	}
+	if i.position > 0 {
+		title = fmt.Sprintf("%v. %v", i.position, title)
+	}
+	if i.marked {
+		title = "✓ " + title
+	}
+	return title
 }
 
 func (i MovieItem) Description() string {
-	/*
-		text := fmt.Sprintf("language:%v\n", i.movie.Language)
-		text = text + fmt.Sprintf("title:%v\n", i.movie.Title)
-		return text
-	*/
-	return i.movie.IMDBLink
+	parts := []string{}
+	if len(i.movie.Genres) > 0 {
+		parts = append(parts, strings.Join(i.movie.Genres, ", "))
+	}
+	if i.movie.RunTime > 0 {
+		parts = append(parts, fmt.Sprint(i.movie.RunTime))
+	}
+	return strings.Join(parts, " · ")
 }
 
 func (i MovieItem) FilterValue() string {
 	text := fmt.Sprintf("language:%v\n", i.movie.Language)
 	text = text + fmt.Sprintf("title:%v\n", i.movie.Title)
+	text = text + fmt.Sprintf("year:%v\n", i.movie.ReleaseYear)
+	text = text + fmt.Sprintf("genres:%v\n", strings.Join(i.movie.Genres, ","))
+	text = text + fmt.Sprintf("directors:%v\n", strings.Join(i.movie.Directors, ","))
 	return text
 }
 
 type model struct {
-	list list.Model
+	list   list.Model
+	keys   *uiKeyMap
+	client *Client
+	// Size of the detail pane
+	detailWidth  int
+	detailHeight int
+	// status is the result of the last action
+	status    string
+	statusErr bool
+	// loadC streams in films while loading is set
+	loadC    <-chan tea.Msg
+	loading  bool
+	spinner  spinner.Model
+	progress RecommendProgress
+	// all is every film loaded, the list only shows the ones matching facets,
+	// in sortMode order
+	all      []*Movie
+	sortMode string
+	facets   facetFilter
+	// shortlist is kept in the Store, if there is one. marked films are the
+	// ones the next shortlist key acts on
+	shortlist     []*Movie
+	marked        map[string]bool
+	showShortlist bool
+	// The facet pane replaces the detail pane while it is open
+	facetsOpen  bool
+	facetCursor int
+	// posters are keyed by TMDB poster path, and nil if posters are off
+	posters        map[string]*posterState
+	posterProtocol string
+	// posterOut is where the graphics protocols draw, around bubbletea
+	posterOut io.Writer
+	// posterDrawn is shared between copies of the model, as drawing happens
+	// outside of Update
+	posterDrawn *posterPlacement
+	// bracket takes over the whole screen while it is open
+	bracket *bracketModel
+}
+
+// Messages sent by loadRecommendations
+type (
+	movieLoadedMsg struct{ movie *Movie }
+	progressMsg    RecommendProgress
+	loadDoneMsg    struct{ err error }
+)
+
+// waitForLoad waits for the next message from the loader
+func waitForLoad(loadC <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-loadC
+	}
+}
+
+// loadRecommendations runs the recommend pipeline, filling in the details on
+// each film and sending it to loadC. It stops when ctx is cancelled
+func (c *Client) loadRecommendations(ctx context.Context, me *PersonInfo, filter *MovieFilterOpts, collect *MovieCollectOpts, loadC chan<- tea.Msg) {
+	send := func(msg tea.Msg) {
+		select {
+		case loadC <- msg:
+		case <-ctx.Done():
+		}
+	}
+	movieC := make(chan *Movie)
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.StreamRecommendationsWithProgress(ctx, me, filter, collect, movieC, func(p RecommendProgress) {
+			send(progressMsg(p))
+		})
+	}()
+	for movie := range movieC {
+		c.FillDetails(ctx, movie)
+		send(movieLoadedMsg{movie: movie})
+	}
+	send(loadDoneMsg{err: <-errC})
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	if m.loadC == nil {
+		return nil
+	}
+	return tea.Batch(m.spinner.Tick, waitForLoad(m.loadC))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	m, cmd := m.update(msg)
+	// The bracket covers the list, so only its posters are loaded while it's open
+	var pc tea.Cmd
+	if m.bracket != nil {
+		pc = m.bracket.posterCmd(msg)
+	} else {
+		pc = m.posterCmd(msg)
+	}
+	if pc != nil {
+		if cmd == nil {
+			return m, pc
+		}
+		cmd = tea.Batch(cmd, pc)
+	}
+	return m, cmd
+}
+
+func (m model) update(msg tea.Msg) (model, tea.Cmd) {
+	if m.bracket != nil {
+		switch msg.(type) {
+		case tea.KeyMsg, posterDrawMsg:
+			return m, m.bracket.update(msg)
+		case tea.WindowSizeMsg:
+			m.bracket.update(msg)
+		}
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if msg.String() == "ctrl+c" {
 			return m, tea.Quit
 		}
+		// Don't steal keys while typing in a filter
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		if m.facetsOpen {
+			return m, m.updateFacets(msg)
+		}
+		switch {
+		case key.Matches(msg, m.keys.sort):
+			m.sortMode = nextSortMode(m.sortMode)
+			m.setStatus(fmt.Sprintf("Sorted by %v", m.sortMode), false)
+			return m, m.refresh()
+		case key.Matches(msg, m.keys.facets):
+			m.facetsOpen = true
+			return m, nil
+		case key.Matches(msg, m.keys.showShortlist):
+			m.showShortlist = !m.showShortlist
+			return m, m.refresh()
+		case key.Matches(msg, m.keys.bracket):
+			m.startBracket()
+			return m, nil
+		}
+		item, ok := m.list.SelectedItem().(MovieItem)
+		if !ok {
+			break
+		}
+		switch {
+		case key.Matches(msg, m.keys.radarr):
+			m.setStatus(fmt.Sprintf("Adding %v to Radarr…", item.movie.Title), false)
+			return m, actionAddToRadarr(m.client, item.movie)
+		case key.Matches(msg, m.keys.openIMDB):
+			return m, actionOpen("IMDB", item.movie.IMDBLink)
+		case key.Matches(msg, m.keys.openLetterboxd):
+			return m, actionOpen("Letterboxd", item.movie.LetterboxdLink())
+		case key.Matches(msg, m.keys.openTMDB):
+			return m, actionOpen("TMDB", item.movie.TMDBLink())
+		case key.Matches(msg, m.keys.copyLink):
+			return m, actionCopy(item.movie.IMDBLink)
+		case key.Matches(msg, m.keys.dismiss):
+			return m, actionDismiss(m.client, item.movie, 0)
+		case key.Matches(msg, m.keys.snooze):
+			return m, actionDismiss(m.client, item.movie, uiSnooze)
+		case key.Matches(msg, m.keys.shortlist):
+			return m, m.toggleShortlist(item.movie)
+		case key.Matches(msg, m.keys.mark):
+			m.toggleMark(item.movie)
+			m.list.CursorDown()
+			return m, m.refresh()
+		case key.Matches(msg, m.keys.moveUp):
+			return m, m.moveShortlisted(item.movie, -1)
+		case key.Matches(msg, m.keys.moveDown):
+			return m, m.moveShortlisted(item.movie, 1)
+		}
+	case movieLoadedMsg:
+		m.all = append(m.all, msg.movie)
+		return m, tea.Batch(m.refresh(), waitForLoad(m.loadC))
+	case progressMsg:
+		m.progress = RecommendProgress(msg)
+		return m, waitForLoad(m.loadC)
+	case loadDoneMsg:
+		m.loading = false
+		if msg.err != nil && msg.err != context.Canceled {
+			m.setStatus(fmt.Sprintf("Error loading films: %v", msg.err), true)
+		}
+		return m, nil
+	case spinner.TickMsg:
+		if !m.loading {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case posterMsg:
+		m.posters[msg.path] = &posterState{poster: msg.poster, err: msg.err}
+		return m, nil
+	case posterDrawMsg:
+		m.drawPoster(msg.path)
+		return m, nil
+	case bracketDoneMsg:
+		m.bracket = nil
+		// The bracket drew its own posters over the screen
+		if m.posterDrawn != nil {
+			*m.posterDrawn = posterPlacement{}
+		}
+		if msg.winner != nil {
+			m.setStatus(fmt.Sprintf("%v won the bracket", msg.winner.Title), false)
+			for i, li := range m.list.Items() {
+				if item, ok := li.(MovieItem); ok && shortlistID(item.movie) == shortlistID(msg.winner) {
+					m.list.Select(i)
+					break
+				}
+			}
+		}
+		return m, nil
+	case actionMsg:
+		if msg.err != nil {
+			m.setStatus(msg.err.Error(), true)
+		} else {
+			m.setStatus(msg.status, false)
+		}
+		if msg.removeID != "" {
+			m.removeMovie(msg.removeID)
+		}
+		return m, nil
 	case tea.WindowSizeMsg:
 		h, v := docStyle.GetFrameSize()
-		m.list.SetSize(msg.Width-h, msg.Height-v)
+		width := msg.Width - h
+		// Leave a line for the status bar
+		height := msg.Height - v - 1
+		listWidth := width * 2 / 5
+		m.list.SetSize(listWidth, height)
+		m.detailWidth = width - listWidth - detailStyle.GetHorizontalFrameSize()
+		m.detailHeight = height
+		// The screen is painted again from scratch
+		if m.posterDrawn != nil {
+			*m.posterDrawn = posterPlacement{}
+		}
 	}
 
 	var cmd tea.Cmd
@@ -59,17 +437,336 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) View() string {
-	return docStyle.Render(m.list.View())
+	if m.bracket != nil {
+		return m.bracket.View()
+	}
+	var detail string
+	if m.facetsOpen {
+		detail = m.facetsView()
+	} else if item, ok := m.list.SelectedItem().(MovieItem); ok {
+		detail = m.posterView(item.movie) + renderMovieDetail(item.movie, m.detailWidth)
+	}
+	status := statusStyle.Render(m.status)
+	if m.statusErr {
+		status = statusErrStyle.Render(m.status)
+	}
+	if progress := m.progressView(); progress != "" {
+		status = progress + "  " + status
+	}
+	return docStyle.Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			m.list.View(),
+			detailStyle.Width(m.detailWidth).MaxHeight(m.detailHeight).Render(detail),
+		),
+		status,
+	))
 }
 
-func NewUI(movies []*Movie) error {
-	mi := []list.Item{}
-	for _, m := range movies {
-		mi = append(mi, MovieItem{movie: m})
+// progressView shows the loading counts, with a spinner while still loading
+func (m model) progressView() string {
+	if m.loadC == nil {
+		return ""
+	}
+	counts := fmt.Sprintf("%v collected · %v enriched · %v filtered · %v shown",
+		m.progress.Collected, m.progress.Enriched, m.progress.Filtered, len(m.list.Items()))
+	if m.loading {
+		return m.spinner.View() + " Loading… " + progressStyle.Render(counts)
+	}
+	return progressStyle.Render(counts)
+}
+
+func (m *model) setStatus(status string, isErr bool) {
+	m.status = status
+	m.statusErr = isErr
+}
+
+// removeMovie takes the film with the given IMDB ID out of the list. Actions
+// finish asynchronously, so the selection may have moved on since
+func (m *model) removeMovie(imdbID string) {
+	for i, movie := range m.all {
+		if movie.IMDBID == imdbID {
+			m.all = append(m.all[:i], m.all[i+1:]...)
+			break
+		}
 	}
-	m := model{list: list.New(mi, list.NewDefaultDelegate(), 0, 0)}
-	m.list.Title = "Movies!!"
+	m.refresh()
+}
+
+// refresh rebuilds the list from all the films, or the shortlist, keeping the
+// same film selected if it is still there
+func (m *model) refresh() tea.Cmd {
+	var selected string
+	if item, ok := m.list.SelectedItem().(MovieItem); ok {
+		selected = shortlistID(item.movie)
+	}
+	source := m.all
+	if m.showShortlist {
+		source = m.shortlist
+	}
+	movies := []*Movie{}
+	for _, movie := range source {
+		if m.facets.matches(movie, "") {
+			movies = append(movies, movie)
+		}
+	}
+	// The shortlist is in the order we picked
+	if !m.showShortlist {
+		sortMovies(movies, m.sortMode)
+	}
+	items := make([]list.Item, len(movies))
+	for i, movie := range movies {
+		id := shortlistID(movie)
+		item := MovieItem{movie: movie, marked: m.marked[id]}
+		if pos := shortlistIndex(m.shortlist, id); pos >= 0 {
+			item.shortlisted = true
+			if m.showShortlist {
+				item.position = pos + 1
+			}
+		}
+		items[i] = item
+	}
+	cmd := m.list.SetItems(items)
+	for i, movie := range movies {
+		if shortlistID(movie) == selected {
+			m.list.Select(i)
+			break
+		}
+	}
+	if m.list.Index() >= len(items) && len(items) > 0 {
+		m.list.Select(len(items) - 1)
+	}
+	m.list.Title = m.listTitle()
+	return cmd
+}
+
+func (m model) listTitle() string {
+	title := "Movies!!"
+	if m.showShortlist {
+		title = "Shortlist"
+	} else if m.sortMode != SortLoaded {
+		title += " · by " + m.sortMode
+	}
+	if n := m.facets.active(); n > 0 {
+		title += fmt.Sprintf(" · %v facets", n)
+	}
+	return title
+}
+
+func nextSortMode(mode string) string {
+	for i, m := range uiSortModes {
+		if m == mode {
+			return uiSortModes[(i+1)%len(uiSortModes)]
+		}
+	}
+	return uiSortModes[0]
+}
+
+// updateFacets handles keys while the facet pane is open
+func (m *model) updateFacets(msg tea.KeyMsg) tea.Cmd {
+	values := facetCounts(m.all, m.facets)
+	// Values can drop out as other facets are toggled
+	if m.facetCursor >= len(values) {
+		m.facetCursor = len(values) - 1
+	}
+	if m.facetCursor < 0 {
+		m.facetCursor = 0
+	}
+	switch {
+	case key.Matches(msg, m.keys.facetClose):
+		m.facetsOpen = false
+	case key.Matches(msg, m.keys.facetUp):
+		if m.facetCursor > 0 {
+			m.facetCursor--
+		}
+	case key.Matches(msg, m.keys.facetDown):
+		if m.facetCursor < len(values)-1 {
+			m.facetCursor++
+		}
+	case key.Matches(msg, m.keys.facetToggle):
+		if m.facetCursor < len(values) {
+			v := values[m.facetCursor]
+			m.facets.toggle(v.Group, v.Value)
+			return m.refresh()
+		}
+	case key.Matches(msg, m.keys.facetClear):
+		m.facets = facetFilter{}
+		return m.refresh()
+	}
+	return nil
+}
+
+// facetsView is the facet pane, scrolled to keep the cursor in view
+func (m model) facetsView() string {
+	lines := []string{
+		detailTitleStyle.Render("Facets"),
+		detailLabelStyle.Render("space toggles, c clears, F closes"),
+	}
+	cursorLine := 0
+	group := ""
+	for i, v := range facetCounts(m.all, m.facets) {
+		if v.Group != group {
+			group = v.Group
+			lines = append(lines, "", detailLabelStyle.Render(group))
+		}
+		check := "[ ]"
+		if v.Active {
+			check = "[x]"
+		}
+		line := fmt.Sprintf("%v %v (%v)", check, v.Value, v.Count)
+		if i == m.facetCursor {
+			line = facetCursorStyle.Render(line)
+			cursorLine = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	if m.detailHeight > 0 && len(lines) > m.detailHeight {
+		start := cursorLine - m.detailHeight + 1
+		if start < 0 {
+			start = 0
+		}
+		lines = lines[start : start+m.detailHeight]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderMovieDetail is the detail pane for a single movie
+func renderMovieDetail(m *Movie, width int) string {
+	var b strings.Builder
+	title := m.Title
+	if m.ReleaseYear > 0 {
+		title = fmt.Sprintf("%v (%v)", m.Title, m.ReleaseYear)
+	}
+	b.WriteString(detailTitleStyle.Render(title) + "\n")
+	if len(m.Directors) > 0 {
+		b.WriteString("Directed by " + strings.Join(m.Directors, ", ") + "\n")
+	}
+	b.WriteString("\n")
+
+	facts := []string{}
+	if len(m.Genres) > 0 {
+		facts = append(facts, strings.Join(m.Genres, ", "))
+	}
+	if m.RunTime > 0 {
+		facts = append(facts, fmt.Sprint(m.RunTime))
+	}
+	if m.Rating > 0 {
+		facts = append(facts, fmt.Sprintf("%.1f/10", m.Rating))
+	}
+	if m.Language != "" {
+		facts = append(facts, m.Language)
+	}
+	if len(facts) > 0 {
+		b.WriteString(strings.Join(facts, " · ") + "\n\n")
+	}
+	if m.Overview != "" {
+		b.WriteString(lipgloss.NewStyle().Width(width).Render(m.Overview) + "\n\n")
+	}
+
+	field := func(label, value string) {
+		b.WriteString(detailLabelStyle.Render(label+": ") + value + "\n")
+	}
+	if len(m.StreamingOn) > 0 {
+		providers := []string{}
+		for _, p := range m.StreamingOn {
+			if ContainsString(m.StreamingOnMy, p) {
+				p = myStreamingStyle.Render(p)
+			}
+			providers = append(providers, p)
+		}
+		field("Streaming", strings.Join(providers, ", "))
+	} else {
+		field("Streaming", "nowhere")
+	}
+	if len(m.AvailableOn) > 0 {
+		field("Library", myStreamingStyle.Render(strings.Join(m.AvailableOn, ", ")))
+	} else {
+		field("Library", "not in my library")
+	}
+	if len(m.WatchedOn) > 0 {
+		field("Watched", strings.Join(m.WatchedOn, ", "))
+	}
+	if m.RequestStatus != "" {
+		field("Requested", m.RequestStatus)
+	}
+	if len(m.Cast) > 0 {
+		field("Cast", strings.Join(m.Cast, ", "))
+	}
+	if m.IMDBLink != "" {
+		b.WriteString("\n" + m.IMDBLink + "\n")
+	}
+	return b.String()
+}
+
+func newModel(c *Client, movies []*Movie) model {
+	keys := newUIKeyMap()
+	m := model{
+		list:     list.New(nil, list.NewDefaultDelegate(), 0, 0),
+		keys:     keys,
+		client:   c,
+		all:      append([]*Movie{}, movies...),
+		marked:   map[string]bool{},
+		sortMode: SortLoaded,
+		facets:   facetFilter{},
+	}
+	if c != nil && c.Store != nil {
+		shortlist, err := c.Shortlist()
+		if err != nil {
+			m.setStatus(fmt.Sprintf("Error loading shortlist: %v", err), true)
+		}
+		m.shortlist = shortlist
+	}
+	if c != nil && c.Posters != nil {
+		m.posterProtocol = c.posterProtocol()
+		if m.posterProtocol != PosterNone {
+			m.posters = map[string]*posterState{}
+			m.posterDrawn = &posterPlacement{}
+		}
+	}
+	m.list.AdditionalShortHelpKeys = keys.shortHelp
+	m.list.AdditionalFullHelpKeys = keys.fullHelp
+	m.refresh()
+	return m
+}
+
+// NewUI browses the given movies. The client is used for actions on them, like
+// adding to Radarr or dismissing
+func NewUI(c *Client, movies []*Movie) error {
+	return runUI(newModel(c, movies))
+}
+
+// RecommendUI opens the UI straight away, and streams recommendations in to it
+// as they are found and enriched
+func (c *Client) RecommendUI(ctx context.Context, me *PersonInfo, filter *MovieFilterOpts, collect *MovieCollectOpts) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Logs would draw over the UI while films load, errors go to the status
+	// bar instead
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(level)
+
+	loadC := make(chan tea.Msg)
+	go c.loadRecommendations(ctx, me, filter, collect, loadC)
+	m := newModel(c, nil)
+	m.loadC = loadC
+	m.loading = true
+	m.spinner = spinner.New()
+	m.spinner.Spinner = spinner.MiniDot
+	return runUI(m)
+}
+
+func runUI(m model) error {
+	m.posterOut = os.Stdout
+	return runProgram(m)
+}
 
+func runProgram(m tea.Model) error {
 	p := tea.NewProgram(m, tea.WithAltScreen())
 
 	if err := p.Start(); err != nil {
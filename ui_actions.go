@@ -0,0 +1,96 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// actionMsg is sent when a TUI action finishes
+type actionMsg struct {
+	status string
+	err    error
+	// removeID is the IMDB ID of a film to take out of the list
+	removeID string
+}
+
+// Overridden in tests
+var (
+	openURL  = openInBrowser
+	copyText = clipboard.WriteAll
+)
+
+// openInBrowser opens the URL with whatever the OS uses for links
+func openInBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
+
+func actionOpen(site, url string) tea.Cmd {
+	return func() tea.Msg {
+		if url == "" {
+			return actionMsg{err: fmt.Errorf("no %v link for this film", site)}
+		}
+		if err := openURL(url); err != nil {
+			return actionMsg{err: fmt.Errorf("opening %v: %w", site, err)}
+		}
+		return actionMsg{status: fmt.Sprintf("Opened %v", url)}
+	}
+}
+
+func actionCopy(url string) tea.Cmd {
+	return func() tea.Msg {
+		if err := copyText(url); err != nil {
+			return actionMsg{err: fmt.Errorf("copying link: %w", err)}
+		}
+		return actionMsg{status: fmt.Sprintf("Copied %v", url)}
+	}
+}
+
+func actionAddToRadarr(c *Client, m *Movie) tea.Cmd {
+	return func() tea.Msg {
+		if c == nil || c.Config == nil || c.Config.RadarrURL == "" {
+			return actionMsg{err: errors.New("radarr is not configured")}
+		}
+		if err := c.AddToRadarr(context.Background(), m); err != nil {
+			return actionMsg{err: fmt.Errorf("adding %v to radarr: %w", m.Title, err)}
+		}
+		return actionMsg{status: fmt.Sprintf("Added %v to Radarr", m.Title)}
+	}
+}
+
+// actionDismiss dismisses the film, or snoozes it if d is set
+func actionDismiss(c *Client, m *Movie, d time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		if c == nil || c.Store == nil {
+			return actionMsg{err: errNoStore}
+		}
+		var until time.Time
+		verb := "Not interested in"
+		if d > 0 {
+			until = time.Now().Add(d)
+			verb = "Snoozed"
+		}
+		if err := c.Store.Dismiss(m.IMDBID, m.Title, m.ReleaseYear, until); err != nil {
+			return actionMsg{err: fmt.Errorf("dismissing %v: %w", m.Title, err)}
+		}
+		return actionMsg{
+			status:   fmt.Sprintf("%v %v", verb, m.Title),
+			removeID: m.IMDBID,
+		}
+	}
+}
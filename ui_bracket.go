@@ -0,0 +1,366 @@
+package letswatch
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Space between the two films in a match
+const uiBracketGap = 4
+
+type bracketKeyMap struct {
+	pickA key.Binding
+	pickB key.Binding
+	undo  key.Binding
+	quit  key.Binding
+}
+
+func newBracketKeyMap() *bracketKeyMap {
+	return &bracketKeyMap{
+		pickA: key.NewBinding(
+			key.WithKeys("left", "h", "1"),
+			key.WithHelp("←/1", "left"),
+		),
+		pickB: key.NewBinding(
+			key.WithKeys("right", "l", "2"),
+			key.WithHelp("→/2", "right"),
+		),
+		undo: key.NewBinding(
+			key.WithKeys("u"),
+			key.WithHelp("u", "undo"),
+		),
+		quit: key.NewBinding(
+			key.WithKeys("q", "esc"),
+			key.WithHelp("q", "quit"),
+		),
+	}
+}
+
+var (
+	bracketHeaderStyle = lipgloss.NewStyle().Bold(true)
+	bracketWinnerStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("42"))
+)
+
+// bracketDoneMsg closes a bracket opened from the main UI
+type bracketDoneMsg struct{ winner *Movie }
+
+// bracketModel asks "A or B?" until the bracket has a winner
+type bracketModel struct {
+	bracket *Bracket
+	keys    *bracketKeyMap
+	client  *Client
+	width   int
+	// recorded is set once the result is in the history
+	recorded bool
+	// standalone quits the program when done, rather than going back to the
+	// main UI
+	standalone bool
+	// posters are shared with the main UI, when opened from it
+	posters        map[string]*posterState
+	posterProtocol string
+	posterOut      io.Writer
+	// drawn is where the posters were last drawn
+	drawn []posterPlacement
+}
+
+func newBracketModel(c *Client, b *Bracket) *bracketModel {
+	m := &bracketModel{
+		bracket: b,
+		keys:    newBracketKeyMap(),
+		client:  c,
+	}
+	if c != nil && c.Posters != nil {
+		m.posterProtocol = c.posterProtocol()
+		if m.posterProtocol != PosterNone {
+			m.posters = map[string]*posterState{}
+		}
+	}
+	return m
+}
+
+func (m *bracketModel) Init() tea.Cmd {
+	return m.posterCmd(nil)
+}
+
+func (m *bracketModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	cmd := m.update(msg)
+	if pc := m.posterCmd(msg); pc != nil {
+		if cmd == nil {
+			return m, pc
+		}
+		cmd = tea.Batch(cmd, pc)
+	}
+	return m, cmd
+}
+
+func (m *bracketModel) update(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return tea.Quit
+		}
+		switch {
+		case key.Matches(msg, m.keys.quit):
+			return m.done()
+		case m.bracket.Done():
+			if msg.Type == tea.KeyEnter {
+				return m.done()
+			}
+		case key.Matches(msg, m.keys.pickA):
+			m.choose(m.bracket.Current().A)
+		case key.Matches(msg, m.keys.pickB):
+			m.choose(m.bracket.Current().B)
+		case key.Matches(msg, m.keys.undo):
+			m.bracket.Undo()
+		}
+	case tea.WindowSizeMsg:
+		m.width = msg.Width - docStyle.GetHorizontalFrameSize()
+		m.drawn = nil
+	case posterMsg:
+		m.posters[msg.path] = &posterState{poster: msg.poster, err: msg.err}
+	case posterDrawMsg:
+		m.drawPosters()
+	}
+	return nil
+}
+
+// choose decides the current match, recording the result in the history once
+// there is a winner
+func (m *bracketModel) choose(winner *Movie) {
+	if err := m.bracket.Choose(winner); err != nil {
+		return
+	}
+	if m.bracket.Done() && !m.recorded && m.client != nil {
+		m.client.recordBracket(m.bracket)
+		m.recorded = true
+	}
+}
+
+func (m *bracketModel) done() tea.Cmd {
+	if m.standalone {
+		return tea.Quit
+	}
+	winner := m.bracket.Winner()
+	return func() tea.Msg {
+		return bracketDoneMsg{winner: winner}
+	}
+}
+
+// showing are the films on screen, the match or the winner
+func (m *bracketModel) showing() []*Movie {
+	if w := m.bracket.Winner(); w != nil {
+		return []*Movie{w}
+	}
+	cur := m.bracket.Current()
+	return []*Movie{cur.A, cur.B}
+}
+
+// columnWidth is the width of each film in a match
+func (m *bracketModel) columnWidth() int {
+	w := (m.width - uiBracketGap) / 2
+	if w < 20 {
+		w = 20
+	}
+	return w
+}
+
+func (m *bracketModel) View() string {
+	var body string
+	if w := m.bracket.Winner(); w != nil {
+		body = lipgloss.JoinVertical(
+			lipgloss.Left,
+			bracketWinnerStyle.Render(fmt.Sprintf("%v wins!", w.Title)),
+			"",
+			m.filmView(w),
+			"",
+			detailLabelStyle.Render("enter/q to finish"),
+		)
+	} else {
+		colW := lipgloss.NewStyle().Width(m.columnWidth())
+		films := m.showing()
+		body = lipgloss.JoinVertical(
+			lipgloss.Left,
+			bracketHeaderStyle.Render(m.header()),
+			"",
+			lipgloss.JoinHorizontal(
+				lipgloss.Top,
+				colW.Render(m.filmView(films[0])),
+				strings.Repeat(" ", uiBracketGap),
+				colW.Render(m.filmView(films[1])),
+			),
+			"",
+			detailLabelStyle.Render(m.helpView()),
+		)
+	}
+	return docStyle.Render(body)
+}
+
+func (m *bracketModel) header() string {
+	current, total := m.bracket.RoundProgress()
+	round := fmt.Sprintf("Round %v", m.bracket.Round())
+	if m.bracket.playoff {
+		round = "Playoff"
+	}
+	return fmt.Sprintf("%v · match %v of %v · %v films", round, current, total, len(m.bracket.Entrants()))
+}
+
+func (m *bracketModel) helpView() string {
+	parts := []string{}
+	for _, k := range []key.Binding{m.keys.pickA, m.keys.pickB, m.keys.undo, m.keys.quit} {
+		parts = append(parts, k.Help().Key+" "+k.Help().Desc)
+	}
+	return strings.Join(parts, " • ")
+}
+
+// filmView is one side of a match
+func (m *bracketModel) filmView(movie *Movie) string {
+	var b strings.Builder
+	b.WriteString(posterSpace(m.posters[movie.PosterPath]))
+	b.WriteString(detailTitleStyle.Render(movie.Title) + "\n")
+	facts := []string{}
+	if movie.ReleaseYear > 0 {
+		facts = append(facts, fmt.Sprint(movie.ReleaseYear))
+	}
+	if movie.RunTime > 0 {
+		facts = append(facts, fmt.Sprint(movie.RunTime))
+	}
+	if len(facts) > 0 {
+		b.WriteString(strings.Join(facts, " · ") + "\n")
+	}
+	if len(movie.Genres) > 0 {
+		b.WriteString(strings.Join(movie.Genres, ", ") + "\n")
+	}
+	if len(movie.Directors) > 0 {
+		b.WriteString(detailLabelStyle.Render("Directed by ") + strings.Join(movie.Directors, ", ") + "\n")
+	}
+	if len(movie.StreamingOnMy) > 0 {
+		b.WriteString(myStreamingStyle.Render(strings.Join(movie.StreamingOnMy, ", ")) + "\n")
+	}
+	if len(movie.AvailableOn) > 0 {
+		b.WriteString(myStreamingStyle.Render("on "+strings.Join(movie.AvailableOn, ", ")) + "\n")
+	}
+	return b.String()
+}
+
+// posterCmd loads the posters of the films on screen, then draws them if the
+// protocol needs it and they have changed
+func (m *bracketModel) posterCmd(msg tea.Msg) tea.Cmd {
+	if m.posters == nil {
+		return nil
+	}
+	switch msg.(type) {
+	case spinner.TickMsg, posterDrawMsg:
+		return nil
+	}
+	cmds := []tea.Cmd{}
+	for _, movie := range m.showing() {
+		path := movie.PosterPath
+		if path != "" && m.posters[path] == nil {
+			m.posters[path] = &posterState{loading: true}
+			cmds = append(cmds, loadPoster(m.client, path, m.posterProtocol))
+		}
+	}
+	if len(cmds) > 0 {
+		return tea.Batch(cmds...)
+	}
+	if m.posterProtocol == PosterHalfBlock || samePlacements(m.placements(), m.drawn) {
+		return nil
+	}
+	return tea.Tick(uiPosterDrawDelay, func(time.Time) tea.Msg {
+		return posterDrawMsg{}
+	})
+}
+
+// placements are where the posters of the films on screen go, for those that
+// are ready to draw
+func (m *bracketModel) placements() []posterPlacement {
+	ret := []posterPlacement{}
+	// The winner has a title line above it, a match the header
+	row := docStyle.GetMarginTop() + 3
+	col := docStyle.GetMarginLeft() + 1
+	for _, movie := range m.showing() {
+		if st := m.posters[movie.PosterPath]; st != nil && st.poster != nil {
+			ret = append(ret, posterPlacement{path: movie.PosterPath, row: row, col: col})
+		}
+		col += m.columnWidth() + uiBracketGap
+	}
+	return ret
+}
+
+func samePlacements(a, b []posterPlacement) bool {
+	if a == nil || b == nil || len(a) != len(b) {
+		return a == nil && b == nil
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// drawPosters writes the graphics protocol posters over the space filmView
+// left for them, unless they are already there
+func (m *bracketModel) drawPosters() {
+	if m.posterOut == nil {
+		return
+	}
+	places := m.placements()
+	if samePlacements(places, m.drawn) {
+		return
+	}
+	m.drawn = places
+	var b strings.Builder
+	if m.posterProtocol == PosterKitty {
+		b.WriteString(kittyClear)
+	}
+	for _, place := range places {
+		b.WriteString(posterAt(m.posters[place.path], place.row, place.col))
+	}
+	_, _ = io.WriteString(m.posterOut, b.String())
+}
+
+// startBracket opens a single elimination bracket over the shortlist, or the
+// films listed if there aren't enough on it
+func (m *model) startBracket() {
+	movies := m.shortlist
+	if len(movies) < 2 {
+		movies = []*Movie{}
+		for _, li := range m.list.Items() {
+			if item, ok := li.(MovieItem); ok {
+				movies = append(movies, item.movie)
+			}
+		}
+	}
+	b, err := NewBracket(movies, BracketSingle)
+	if err != nil {
+		m.setStatus(err.Error(), true)
+		return
+	}
+	m.bracket = newBracketModel(m.client, b)
+	m.bracket.posters = m.posters
+	m.bracket.posterProtocol = m.posterProtocol
+	m.bracket.posterOut = m.posterOut
+	m.bracket.width = m.list.Width() + m.detailWidth + detailStyle.GetHorizontalFrameSize()
+}
+
+// BracketUI runs a bracket between the movies until there is a winner, which
+// is recorded in the history
+func (c *Client) BracketUI(movies []*Movie, mode string) error {
+	b, err := NewBracket(movies, mode)
+	if err != nil {
+		return err
+	}
+	m := newBracketModel(c, b)
+	m.standalone = true
+	m.posterOut = os.Stdout
+	return runProgram(m)
+}
@@ -0,0 +1,193 @@
+package letswatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort orders for the TUI, cycled through in this order
+const (
+	SortLoaded  = "loaded"
+	SortYear    = "year"
+	SortRuntime = "runtime"
+	SortRating  = "rating"
+	SortScore   = "score"
+	SortTitle   = "title"
+)
+
+var uiSortModes = []string{SortLoaded, SortYear, SortRuntime, SortRating, SortScore, SortTitle}
+
+// Facet groups for the TUI. Values in the same group are ORed together, and
+// groups are ANDed
+const (
+	FacetGenre        = "genre"
+	FacetLanguage     = "language"
+	FacetDecade       = "decade"
+	FacetRuntime      = "runtime"
+	FacetAvailability = "availability"
+)
+
+var uiFacetGroups = []string{FacetAvailability, FacetGenre, FacetLanguage, FacetDecade, FacetRuntime}
+
+// Score ranks a movie by its rating, with a bump for anything I can watch
+// right now
+func (m *Movie) Score() float64 {
+	score := m.Rating
+	if len(m.StreamingOnMy) > 0 || len(m.AvailableOn) > 0 {
+		score += 2
+	}
+	return score
+}
+
+// sortMovies sorts in place. Years, runtimes, ratings and scores are highest
+// first. The sort is stable, so ties stay in the order they were loaded
+func sortMovies(movies []*Movie, mode string) {
+	var less func(a, b *Movie) bool
+	switch mode {
+	case SortYear:
+		less = func(a, b *Movie) bool { return a.ReleaseYear > b.ReleaseYear }
+	case SortRuntime:
+		less = func(a, b *Movie) bool { return a.RunTime > b.RunTime }
+	case SortRating:
+		less = func(a, b *Movie) bool { return a.Rating > b.Rating }
+	case SortScore:
+		less = func(a, b *Movie) bool { return a.Score() > b.Score() }
+	case SortTitle:
+		less = func(a, b *Movie) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	default:
+		return
+	}
+	sort.SliceStable(movies, func(i, j int) bool { return less(movies[i], movies[j]) })
+}
+
+// runtimeBucket groups runtimes in to rough lengths
+func runtimeBucket(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return ""
+	case d < 90*time.Minute:
+		return "under 90m"
+	case d < 120*time.Minute:
+		return "90m-2h"
+	case d < 150*time.Minute:
+		return "2h-2h30m"
+	default:
+		return "over 2h30m"
+	}
+}
+
+// movieFacets returns the values the movie has for each facet group
+func movieFacets(m *Movie) map[string][]string {
+	ret := map[string][]string{
+		FacetGenre: m.Genres,
+	}
+	if m.Language != "" {
+		ret[FacetLanguage] = []string{m.Language}
+	}
+	if m.ReleaseYear > 0 {
+		ret[FacetDecade] = []string{fmt.Sprintf("%vs", m.ReleaseYear/10*10)}
+	}
+	if b := runtimeBucket(m.RunTime); b != "" {
+		ret[FacetRuntime] = []string{b}
+	}
+	avail := []string{}
+	if len(m.StreamingOnMy) > 0 {
+		avail = append(avail, "my services")
+	}
+	for _, s := range m.AvailableOn {
+		avail = append(avail, "on "+s)
+	}
+	ret[FacetAvailability] = avail
+	return ret
+}
+
+// facetFilter holds the selected values of each facet group
+type facetFilter map[string]map[string]bool
+
+func (f facetFilter) toggle(group, value string) {
+	if f[group] == nil {
+		f[group] = map[string]bool{}
+	}
+	if f[group][value] {
+		delete(f[group], value)
+	} else {
+		f[group][value] = true
+	}
+}
+
+// matches returns true if the movie has one of the selected values in every
+// group with a selection. skipGroup is ignored, for counting that group
+func (f facetFilter) matches(m *Movie, skipGroup string) bool {
+	facets := movieFacets(m)
+	for group, selected := range f {
+		if group == skipGroup || len(selected) == 0 {
+			continue
+		}
+		found := false
+		for _, v := range facets[group] {
+			if selected[v] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// active returns how many values are selected across all groups
+func (f facetFilter) active() int {
+	ret := 0
+	for _, selected := range f {
+		ret += len(selected)
+	}
+	return ret
+}
+
+type facetValue struct {
+	Group  string
+	Value  string
+	Count  int
+	Active bool
+}
+
+// facetCounts counts the movies with each facet value. The counts for a group
+// take the selections in every other group in to account, so they show how
+// many films selecting that value would add. Groups are in a fixed order, and
+// values are most common first
+func facetCounts(movies []*Movie, f facetFilter) []facetValue {
+	ret := []facetValue{}
+	for _, group := range uiFacetGroups {
+		counts := map[string]int{}
+		for _, m := range movies {
+			if !f.matches(m, group) {
+				continue
+			}
+			for _, v := range movieFacets(m)[group] {
+				counts[v]++
+			}
+		}
+		// Keep selected values around, even when nothing matches them now
+		for v := range f[group] {
+			if _, ok := counts[v]; !ok {
+				counts[v] = 0
+			}
+		}
+		values := []facetValue{}
+		for v, n := range counts {
+			values = append(values, facetValue{Group: group, Value: v, Count: n, Active: f[group][v]})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+		ret = append(ret, values...)
+	}
+	return ret
+}
@@ -0,0 +1,129 @@
+package letswatch
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func facetTestMovies() []*Movie {
+	return []*Movie{
+		{Title: "The Handmaiden", IMDBID: "tt4016934", ReleaseYear: 2016, RunTime: 145 * time.Minute, Rating: 8.1, Language: "ko", Genres: []string{"Thriller", "Romance"}, StreamingOnMy: []string{"Mubi"}},
+		{Title: "Audition", IMDBID: "tt0235198", ReleaseYear: 1999, RunTime: 115 * time.Minute, Rating: 7.2, Language: "ja", Genres: []string{"Horror", "Thriller"}},
+		{Title: "Alien", IMDBID: "tt0078748", ReleaseYear: 1979, RunTime: 117 * time.Minute, Rating: 8.5, Language: "en", Genres: []string{"Horror", "Science Fiction"}, AvailableOn: []string{"plex"}},
+		{Title: "Cure", IMDBID: "tt0123948", ReleaseYear: 1997, RunTime: 111 * time.Minute, Rating: 7.6, Language: "ja", Genres: []string{"Crime", "Horror"}},
+	}
+}
+
+func movieTitles(movies []*Movie) []string {
+	ret := []string{}
+	for _, m := range movies {
+		ret = append(ret, m.Title)
+	}
+	return ret
+}
+
+func TestSortMovies(t *testing.T) {
+	tests := map[string][]string{
+		SortLoaded:  {"The Handmaiden", "Audition", "Alien", "Cure"},
+		SortYear:    {"The Handmaiden", "Audition", "Cure", "Alien"},
+		SortRuntime: {"The Handmaiden", "Alien", "Audition", "Cure"},
+		SortRating:  {"Alien", "The Handmaiden", "Cure", "Audition"},
+		SortScore:   {"Alien", "The Handmaiden", "Cure", "Audition"},
+		SortTitle:   {"Alien", "Audition", "Cure", "The Handmaiden"},
+	}
+	for mode, want := range tests {
+		movies := facetTestMovies()
+		sortMovies(movies, mode)
+		require.Equal(t, want, movieTitles(movies), mode)
+	}
+	require.Equal(t, SortYear, nextSortMode(SortLoaded))
+	require.Equal(t, SortLoaded, nextSortMode(SortTitle))
+}
+
+func TestFacetCounts(t *testing.T) {
+	movies := facetTestMovies()
+	count := func(values []facetValue, group, value string) int {
+		for _, v := range values {
+			if v.Group == group && v.Value == value {
+				return v.Count
+			}
+		}
+		return -1
+	}
+
+	f := facetFilter{}
+	values := facetCounts(movies, f)
+	require.Equal(t, 3, count(values, FacetGenre, "Horror"))
+	require.Equal(t, 2, count(values, FacetLanguage, "ja"))
+	require.Equal(t, 2, count(values, FacetDecade, "1990s"))
+	require.Equal(t, 3, count(values, FacetRuntime, "90m-2h"))
+	require.Equal(t, 1, count(values, FacetAvailability, "my services"))
+	require.Equal(t, 1, count(values, FacetAvailability, "on plex"))
+	// Groups come in a fixed order, most common values first
+	require.Equal(t, FacetAvailability, values[0].Group)
+	require.Equal(t, facetValue{Group: FacetGenre, Value: "Horror", Count: 3}, values[2])
+
+	// Other groups narrow down, but a group's own selection doesn't
+	f.toggle(FacetLanguage, "ja")
+	values = facetCounts(movies, f)
+	require.Equal(t, 2, count(values, FacetGenre, "Horror"))
+	require.Equal(t, 1, count(values, FacetLanguage, "ko"))
+	require.Equal(t, -1, count(values, FacetAvailability, "on plex"))
+
+	// Values in a group are ORed
+	f.toggle(FacetLanguage, "en")
+	var got []*Movie
+	for _, m := range movies {
+		if f.matches(m, "") {
+			got = append(got, m)
+		}
+	}
+	require.Equal(t, []string{"Audition", "Alien", "Cure"}, movieTitles(got))
+
+	// Selected values stick around with no matches
+	f.toggle(FacetAvailability, "my services")
+	values = facetCounts(movies, f)
+	require.Equal(t, 0, count(values, FacetLanguage, "ja"))
+	require.Equal(t, 3, f.active())
+	f.toggle(FacetAvailability, "my services")
+	require.Equal(t, 2, f.active())
+}
+
+func TestUIFacets(t *testing.T) {
+	var m tea.Model = newModel(&Client{}, facetTestMovies())
+	items := func() []string {
+		ret := []string{}
+		for _, i := range m.(model).list.Items() {
+			ret = append(ret, i.(MovieItem).movie.Title)
+		}
+		return ret
+	}
+
+	m, _ = m.Update(keyPress("o"))
+	require.Equal(t, []string{"The Handmaiden", "Audition", "Cure", "Alien"}, items())
+	require.Equal(t, "Movies!! · by year", m.(model).list.Title)
+
+	// The first facet is the most common availability, then genres. Thriller
+	// is the second genre
+	m, _ = m.Update(keyPress("F"))
+	require.Contains(t, m.(model).View(), "[ ] Horror (3)")
+	for _, k := range []string{"j", "j", "j", " "} {
+		m, _ = m.Update(keyPress(k))
+	}
+	require.Equal(t, []string{"The Handmaiden", "Audition"}, items())
+	require.Equal(t, "Movies!! · by year · 1 facets", m.(model).list.Title)
+	require.Contains(t, m.(model).View(), "[x] Thriller (2)")
+
+	// Films loading in respect the facets
+	m, _ = m.Update(movieLoadedMsg{movie: &Movie{Title: "Oldboy", ReleaseYear: 2003, Genres: []string{"Thriller"}}})
+	m, _ = m.Update(movieLoadedMsg{movie: &Movie{Title: "Paddington", ReleaseYear: 2014, Genres: []string{"Comedy"}}})
+	require.Equal(t, []string{"The Handmaiden", "Oldboy", "Audition"}, items())
+
+	m, _ = m.Update(keyPress("c"))
+	require.Len(t, items(), 6)
+	m, _ = m.Update(keyPress("F"))
+	require.False(t, m.(model).facetsOpen)
+}
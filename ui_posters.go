@@ -0,0 +1,180 @@
+package letswatch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Largest poster to draw in the detail pane, in cells. Posters are 2:3, and
+// cells are about twice as tall as they are wide
+const (
+	uiPosterCols = 16
+	uiPosterRows = 12
+)
+
+// The graphics protocols draw straight to the terminal, so wait for the frame
+// to be painted before drawing over it
+const uiPosterDrawDelay = 50 * time.Millisecond
+
+type posterState struct {
+	loading bool
+	poster  *Poster
+	err     error
+	// sent is set once a kitty poster has been sent to the terminal
+	sent bool
+}
+
+// posterPlacement is what a poster was last drawn for, so it is only drawn
+// again once the selection or the layout changes
+type posterPlacement struct {
+	path     string
+	row, col int
+}
+
+// Messages for loading and drawing posters
+type (
+	posterMsg struct {
+		path   string
+		poster *Poster
+		err    error
+	}
+	posterDrawMsg struct{ path string }
+)
+
+// posterProtocol is the configured protocol, or the best guess for this
+// terminal
+func (c *Client) posterProtocol() string {
+	p := PosterAuto
+	if c.Config != nil && c.Config.PosterProtocol != "" {
+		p = c.Config.PosterProtocol
+	}
+	if p == PosterAuto {
+		p = DetectPosterProtocol(os.Getenv)
+	}
+	return p
+}
+
+func loadPoster(c *Client, path, protocol string) tea.Cmd {
+	return func() tea.Msg {
+		img, err := c.Posters.Get(context.Background(), path)
+		if err != nil {
+			return posterMsg{path: path, err: err}
+		}
+		p, err := RenderPoster(img, protocol, uiPosterCols, uiPosterRows)
+		return posterMsg{path: path, poster: p, err: err}
+	}
+}
+
+// selectedPosterPath is the poster for the detail pane, if it is showing one
+func (m model) selectedPosterPath() string {
+	if m.facetsOpen {
+		return ""
+	}
+	if item, ok := m.list.SelectedItem().(MovieItem); ok {
+		return item.movie.PosterPath
+	}
+	return ""
+}
+
+// posterCmd loads the selected film's poster if it hasn't been already. The
+// graphics protocols draw it once the frame has been painted, if it has moved
+// or changed since it was last drawn
+func (m model) posterCmd(msg tea.Msg) tea.Cmd {
+	if m.posters == nil {
+		return nil
+	}
+	switch msg.(type) {
+	case spinner.TickMsg, posterDrawMsg:
+		return nil
+	}
+	path := m.selectedPosterPath()
+	if path != "" && m.posters[path] == nil {
+		m.posters[path] = &posterState{loading: true}
+		return loadPoster(m.client, path, m.posterProtocol)
+	}
+	if m.posterProtocol == PosterHalfBlock || m.posterPlacement() == *m.posterDrawn {
+		return nil
+	}
+	return tea.Tick(uiPosterDrawDelay, func(time.Time) tea.Msg {
+		return posterDrawMsg{path: path}
+	})
+}
+
+// posterPlacement is where the selected poster goes, or nothing if there isn't
+// one ready to draw
+func (m model) posterPlacement() posterPlacement {
+	path := m.selectedPosterPath()
+	if st := m.posters[path]; path == "" || st == nil || st.poster == nil {
+		return posterPlacement{}
+	}
+	return posterPlacement{
+		path: path,
+		row:  docStyle.GetMarginTop() + 1,
+		col: docStyle.GetMarginLeft() + lipgloss.Width(m.list.View()) +
+			detailStyle.GetBorderLeftSize() + detailStyle.GetPaddingLeft() + 1,
+	}
+}
+
+// posterSpace is the space for a poster in a view. Half blocks are drawn in
+// place, the graphics protocols just get the space left blank to draw over
+func posterSpace(st *posterState) string {
+	if st == nil || st.poster == nil {
+		return ""
+	}
+	if st.poster.Protocol == PosterHalfBlock {
+		return st.poster.Data + "\n\n"
+	}
+	return strings.Repeat("\n", st.poster.Rows+1)
+}
+
+// posterAt draws a poster with one of the graphics protocols at row and col,
+// counting from 1. The cursor is put back afterwards for bubbletea. kitty
+// posters are only sent the first time, after that they are placed again
+func posterAt(st *posterState, row, col int) string {
+	data := st.poster.Data
+	if st.poster.Protocol == PosterKitty {
+		data = st.poster.Place
+		if !st.sent {
+			data = st.poster.Data + data
+			st.sent = true
+		}
+	}
+	return fmt.Sprintf("\x1b7\x1b[%v;%vH%v\x1b8", row, col, data)
+}
+
+// posterView is the top of the detail pane
+func (m model) posterView(movie *Movie) string {
+	return posterSpace(m.posters[movie.PosterPath])
+}
+
+// drawPoster writes the poster over the space posterView left for it, unless
+// it is already there. kitty images sit above the text, so any old one is
+// cleared first
+func (m model) drawPoster(path string) {
+	if m.posterOut == nil || path != m.selectedPosterPath() {
+		return
+	}
+	place := m.posterPlacement()
+	if place == *m.posterDrawn {
+		return
+	}
+	*m.posterDrawn = place
+	var b strings.Builder
+	if m.posterProtocol == PosterKitty {
+		b.WriteString(kittyClear)
+	}
+	if place.path != "" {
+		b.WriteString(posterAt(m.posters[place.path], place.row, place.col))
+	}
+	if b.Len() > 0 {
+		_, _ = io.WriteString(m.posterOut, b.String())
+	}
+}
@@ -0,0 +1,97 @@
+package letswatch
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toggleMark selects or deselects a film for the next shortlist key
+func (m *model) toggleMark(movie *Movie) {
+	id := shortlistID(movie)
+	if m.marked[id] {
+		delete(m.marked, id)
+	} else {
+		m.marked[id] = true
+	}
+}
+
+// shortlistTargets are the marked films in the order they are listed, or just
+// the selected one if none are marked. A film listed twice is only returned
+// once
+func (m model) shortlistTargets(selected *Movie) []*Movie {
+	ret := []*Movie{}
+	seen := map[string]bool{}
+	for _, li := range m.list.Items() {
+		item, ok := li.(MovieItem)
+		if !ok {
+			continue
+		}
+		id := shortlistID(item.movie)
+		if m.marked[id] && !seen[id] {
+			seen[id] = true
+			ret = append(ret, item.movie)
+		}
+	}
+	if len(ret) == 0 {
+		ret = append(ret, selected)
+	}
+	return ret
+}
+
+// toggleShortlist adds the targets to the end of the shortlist, or takes them
+// off if they are all on it already
+func (m *model) toggleShortlist(selected *Movie) tea.Cmd {
+	movies := m.shortlistTargets(selected)
+	remove := true
+	for _, movie := range movies {
+		if shortlistIndex(m.shortlist, shortlistID(movie)) < 0 {
+			remove = false
+		}
+	}
+	shortlist := append([]*Movie{}, m.shortlist...)
+	for _, movie := range movies {
+		i := shortlistIndex(shortlist, shortlistID(movie))
+		switch {
+		case remove:
+			shortlist = append(shortlist[:i], shortlist[i+1:]...)
+		case i < 0:
+			shortlist = append(shortlist, movie)
+		}
+	}
+
+	name := movies[0].Title
+	if len(movies) > 1 {
+		name = fmt.Sprintf("%v films", len(movies))
+	}
+	status := fmt.Sprintf("Shortlisted %v", name)
+	if remove {
+		status = fmt.Sprintf("Removed %v from the shortlist", name)
+	}
+	m.marked = map[string]bool{}
+	m.setShortlist(shortlist, status)
+	return m.refresh()
+}
+
+// moveShortlisted moves a film up (negative) or down the shortlist
+func (m *model) moveShortlisted(movie *Movie, by int) tea.Cmd {
+	i := shortlistIndex(m.shortlist, shortlistID(movie))
+	to := i + by
+	if i < 0 || to < 0 || to >= len(m.shortlist) {
+		return nil
+	}
+	m.setShortlist(moveMovie(m.shortlist, i, to), fmt.Sprintf("Moved %v to number %v", movie.Title, to+1))
+	return m.refresh()
+}
+
+// setShortlist keeps the new shortlist, saving it if there is a Store
+func (m *model) setShortlist(shortlist []*Movie, status string) {
+	m.shortlist = shortlist
+	if m.client != nil && m.client.Store != nil {
+		if err := m.client.SaveShortlist(shortlist); err != nil {
+			m.setStatus(fmt.Sprintf("Error saving shortlist: %v", err), true)
+			return
+		}
+	}
+	m.setStatus(status, false)
+}
@@ -0,0 +1,82 @@
+package letswatch
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIShortlist(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	c := &Client{Store: store}
+	var m tea.Model = newModel(c, facetTestMovies())
+	titles := func() []string {
+		ret := []string{}
+		for _, i := range m.(model).list.Items() {
+			ret = append(ret, i.(MovieItem).Title())
+		}
+		return ret
+	}
+
+	// Select the first two, then shortlist them together
+	m, _ = m.Update(keyPress(" "))
+	m, _ = m.Update(keyPress(" "))
+	require.Equal(t, "✓ Audition (1999)", titles()[1])
+	m, _ = m.Update(keyPress("s"))
+	require.Equal(t, "Shortlisted 2 films", m.(model).status)
+	require.Equal(t, []string{"★ The Handmaiden (2016)", "★ Audition (1999)", "Alien (1979)", "Cure (1997)"}, titles())
+
+	m, _ = m.Update(keyPress("S"))
+	require.Equal(t, "Shortlist", m.(model).list.Title)
+	require.Equal(t, []string{"1. ★ The Handmaiden (2016)", "2. ★ Audition (1999)"}, titles())
+	require.Equal(t, 1, m.(model).list.Index())
+	m, _ = m.Update(keyPress("K"))
+	require.Equal(t, []string{"1. ★ Audition (1999)", "2. ★ The Handmaiden (2016)"}, titles())
+	require.Equal(t, "Moved Audition to number 1", m.(model).status)
+	// Can't go any higher
+	m, _ = m.Update(keyPress("K"))
+	require.Equal(t, []string{"1. ★ Audition (1999)", "2. ★ The Handmaiden (2016)"}, titles())
+
+	saved, err := store.Shortlist()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Audition", "The Handmaiden"}, movieTitles(saved))
+
+	// It is still there next time, and s on its own takes a film off again
+	m = newModel(c, facetTestMovies())
+	require.Equal(t, []string{"★ The Handmaiden (2016)", "★ Audition (1999)", "Alien (1979)", "Cure (1997)"}, titles())
+	m, _ = m.Update(keyPress("s"))
+	require.Equal(t, "Removed The Handmaiden from the shortlist", m.(model).status)
+	saved, err = store.Shortlist()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Audition"}, movieTitles(saved))
+}
+
+func TestUIShortlistDuplicates(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	movies := facetTestMovies()
+	// The same film from two sources
+	movies = append(movies, &Movie{Title: "Audition", IMDBID: "tt0235198", ReleaseYear: 1999})
+	var m tea.Model = newModel(&Client{Store: store}, movies)
+
+	m, _ = m.Update(keyPress("j"))
+	m, _ = m.Update(keyPress(" "))
+	m, _ = m.Update(keyPress("s"))
+	require.Equal(t, "Shortlisted Audition", m.(model).status)
+	saved, err := store.Shortlist()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Audition"}, movieTitles(saved))
+
+	// Marking either copy takes the film off once
+	m = newModel(&Client{Store: store}, movies)
+	m, _ = m.Update(keyPress("j"))
+	m, _ = m.Update(keyPress(" "))
+	m, _ = m.Update(keyPress("s"))
+	require.Equal(t, "Removed Audition from the shortlist", m.(model).status)
+	saved, err = store.Shortlist()
+	require.NoError(t, err)
+	require.Empty(t, saved)
+}
@@ -0,0 +1,172 @@
+package letswatch
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	tmdb "github.com/cyruzin/golang-tmdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMovieWithTMDBCredits(t *testing.T) {
+	var details tmdb.MovieDetails
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": 290098,
+		"imdb_id": "tt4016934",
+		"title": "The Handmaiden",
+		"release_date": "2016-06-01",
+		"overview": "A young woman is hired as a handmaiden.",
+		"credits": {
+			"cast": [
+				{"name": "Kim Min-hee"}, {"name": "Kim Tae-ri"}, {"name": "Ha Jung-woo"},
+				{"name": "Cho Jin-woong"}, {"name": "Moon So-ri"}, {"name": "Kim Hae-sook"}
+			],
+			"crew": [{"name": "Park Chan-wook", "job": "Director"}, {"name": "Chung Seo-kyung", "job": "Screenplay"}]
+		},
+		"keywords": {"keywords": [{"id": 818, "name": "based on novel or book"}, {"id": 9748, "name": "revenge"}]}
+	}`), &details))
+	got := NewMovieWithTMDB(&details)
+	require.Equal(t, "A young woman is hired as a handmaiden.", got.Overview)
+	require.Equal(t, []string{"Park Chan-wook"}, got.Directors)
+	require.Equal(t, []string{"Kim Min-hee", "Kim Tae-ri", "Ha Jung-woo", "Cho Jin-woong", "Moon So-ri"}, got.Cast)
+	require.Equal(t, 2016, got.ReleaseYear)
+	require.Equal(t, []string{"based on novel or book", "revenge"}, got.Keywords)
+}
+
+func TestRenderMovieDetail(t *testing.T) {
+	got := renderMovieDetail(&Movie{
+		Title:         "The Handmaiden",
+		ReleaseYear:   2016,
+		Directors:     []string{"Park Chan-wook"},
+		Genres:        []string{"Thriller", "Drama"},
+		RunTime:       145 * time.Minute,
+		Rating:        8.3,
+		Overview:      "A young woman is hired as a handmaiden.",
+		StreamingOn:   []string{"Shudder", "Hulu"},
+		StreamingOnMy: []string{"Shudder"},
+		AvailableOn:   []string{},
+		RequestStatus: "pending",
+		Cast:          []string{"Kim Min-hee", "Kim Tae-ri"},
+		IMDBLink:      "https://www.imdb.com/title/tt4016934",
+	}, 60)
+	for _, want := range []string{
+		"The Handmaiden (2016)",
+		"Directed by Park Chan-wook",
+		"Thriller, Drama · 2h25m0s · 8.3/10",
+		"A young woman is hired as a handmaiden.",
+		"Shudder",
+		"Hulu",
+		"not in my library",
+		"pending",
+		"Kim Min-hee, Kim Tae-ri",
+		"https://www.imdb.com/title/tt4016934",
+	} {
+		require.True(t, strings.Contains(got, want), "missing %q in:\n%v", want, got)
+	}
+
+	item := MovieItem{movie: &Movie{Genres: []string{"Horror"}, RunTime: 90 * time.Minute}}
+	require.Equal(t, "Horror · 1h30m0s", item.Description())
+}
+
+func keyPress(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestUIActions(t *testing.T) {
+	var opened, copied []string
+	openURL = func(url string) error {
+		opened = append(opened, url)
+		return nil
+	}
+	copyText = func(s string) error {
+		copied = append(copied, s)
+		return nil
+	}
+	defer func() {
+		openURL = openInBrowser
+		copyText = clipboard.WriteAll
+	}()
+
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "letswatch.db"))
+	require.NoError(t, err)
+	c := &Client{Store: store}
+	movies := []*Movie{
+		{Title: "The Handmaiden", ReleaseYear: 2016, IMDBID: "tt4016934", IMDBLink: "https://www.imdb.com/title/tt4016934", TMDBID: "290098"},
+		{Title: "Audition", ReleaseYear: 1999, IMDBID: "tt0235198", IMDBLink: "https://www.imdb.com/title/tt0235198", TMDBID: "11075"},
+	}
+	var m tea.Model = newModel(c, movies)
+
+	// run sends a key, runs any command it returns and feeds the result back
+	run := func(k string) {
+		var cmd tea.Cmd
+		m, cmd = m.Update(keyPress(k))
+		if cmd != nil {
+			if msg, ok := cmd().(actionMsg); ok {
+				m, _ = m.Update(msg)
+			}
+		}
+	}
+
+	run("L")
+	run("T")
+	run("I")
+	require.Equal(t, []string{
+		"https://letterboxd.com/tmdb/290098",
+		"https://www.themoviedb.org/movie/290098",
+		"https://www.imdb.com/title/tt4016934",
+	}, opened)
+	require.Equal(t, "Opened https://www.imdb.com/title/tt4016934", m.(model).status)
+
+	run("y")
+	require.Equal(t, []string{"https://www.imdb.com/title/tt4016934"}, copied)
+
+	run("s")
+	require.Equal(t, "★ The Handmaiden (2016)", m.(model).list.SelectedItem().(MovieItem).Title())
+	run("s")
+	require.Equal(t, "The Handmaiden (2016)", m.(model).list.SelectedItem().(MovieItem).Title())
+
+	run("x")
+	require.Len(t, m.(model).list.Items(), 1)
+	require.Equal(t, "Not interested in The Handmaiden", m.(model).status)
+	require.False(t, m.(model).statusErr)
+	h, err := store.Get("tt4016934")
+	require.NoError(t, err)
+	require.True(t, h.IsDismissed(time.Now()))
+
+	// Errors end up in the status bar
+	openURL = func(string) error { return errors.New("no browser") }
+	run("I")
+	require.Equal(t, "opening IMDB: no browser", m.(model).status)
+	require.True(t, m.(model).statusErr)
+}
+
+func TestUILoading(t *testing.T) {
+	loadC := make(chan tea.Msg)
+	m := newModel(&Client{}, nil)
+	m.loadC = loadC
+	m.loading = true
+	require.NotNil(t, m.Init())
+
+	var tm tea.Model = m
+	tm, cmd := tm.Update(progressMsg{Collected: 10, Enriched: 3, Filtered: 2})
+	require.NotNil(t, cmd)
+	tm, _ = tm.Update(movieLoadedMsg{movie: &Movie{Title: "The Handmaiden", ReleaseYear: 2016}})
+	require.Len(t, tm.(model).list.Items(), 1)
+	require.Contains(t, tm.(model).progressView(), "Loading…")
+	require.Contains(t, tm.(model).progressView(), "10 collected · 3 enriched · 2 filtered · 1 shown")
+
+	tm, cmd = tm.Update(loadDoneMsg{err: errors.New("letterboxd is down")})
+	require.Nil(t, cmd)
+	require.False(t, tm.(model).loading)
+	require.NotContains(t, tm.(model).progressView(), "Loading…")
+	require.Equal(t, "Error loading films: letterboxd is down", tm.(model).status)
+
+	// Static lists don't show progress at all
+	require.Empty(t, newModel(&Client{}, nil).progressView())
+}
@@ -2,7 +2,9 @@ package letswatch
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/gobwas/glob"
 	"github.com/rs/zerolog/log"
@@ -35,12 +37,24 @@ func inBetween(i, min, max int) bool {
 	}
 }
 
+// CompileGlobs compiles each of the given globs, returning an error for the
+// first one that isn't valid
+func CompileGlobs(globs []string) ([]glob.Glob, error) {
+	ret := make([]glob.Glob, len(globs))
+	for i, matchGlob := range globs {
+		g, err := glob.Compile(matchGlob)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", matchGlob, err)
+		}
+		ret[i] = g
+	}
+	return ret, nil
+}
+
 // MatchesGlobOf returns true if an item matches any of the given globs
-func MatchesGlobOf(item string, globs []string) bool {
-	for _, matchGlob := range globs {
-		g := glob.MustCompile(matchGlob)
-		got := g.Match(item)
-		if got {
+func MatchesGlobOf(item string, globs []glob.Glob) bool {
+	for _, g := range globs {
+		if g.Match(item) {
 			return true
 		}
 	}
@@ -84,3 +98,21 @@ func Intersection(s1, s2 []string) (inter []string) {
 	inter = removeDups(inter)
 	return
 }
+
+// writeFileAtomic writes data to a temp file next to path, then renames it
+// over path, so nothing reading it ever sees half a file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,7 +1,9 @@
 package letswatch
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -42,3 +44,19 @@ func TestValidateEnvs(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ballot.yaml")
+	require.NoError(t, writeFileAtomic(path, []byte("one")))
+	require.NoError(t, writeFileAtomic(path, []byte("two")))
+	got, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "two", string(got))
+	// No temp files are left behind
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.Error(t, writeFileAtomic(filepath.Join(dir, "missing", "ballot.yaml"), []byte("one")))
+}
@@ -0,0 +1,397 @@
+package letswatch
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ways of counting the votes
+const (
+	// TallyInstantRunoff knocks out the film with the fewest first choices,
+	// moving its votes to their next choice, until one has a majority
+	TallyInstantRunoff = "irv"
+	// TallySchulze picks the film that beats every other one head to head,
+	// following chains of wins where there isn't one
+	TallySchulze = "schulze"
+)
+
+var (
+	errNoBallotPath = errors.New("no ballot file is configured, set 'ballot' in the config")
+	errNoVote       = errors.New("no vote is open, start one with 'letswatch vote open'")
+	errNoVotes      = errors.New("no votes have been cast")
+)
+
+// Ballot is an open vote between some films
+type Ballot struct {
+	Opened     time.Time `yaml:"opened" json:"opened"`
+	Candidates []*Movie  `yaml:"candidates" json:"candidates"`
+	Votes      []*Vote   `yaml:"votes,omitempty" json:"votes,omitempty"`
+}
+
+// Vote is one person's films in order of preference. Films left off are
+// ranked below all the others
+type Vote struct {
+	Voter string `yaml:"voter" json:"voter"`
+	// Ranking is the candidates' shortlistIDs, favourite first
+	Ranking []string  `yaml:"ranking" json:"ranking"`
+	Cast    time.Time `yaml:"cast" json:"cast"`
+}
+
+// NewBallot opens a vote between the movies
+func NewBallot(movies []*Movie, now time.Time) (*Ballot, error) {
+	if len(movies) < 2 {
+		return nil, errors.New("a vote needs at least 2 films")
+	}
+	return &Ballot{
+		Opened:     now,
+		Candidates: movies,
+	}, nil
+}
+
+// LoadBallot reads a ballot saved with Save
+func LoadBallot(path string) (*Ballot, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errNoVote
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &Ballot{}
+	if err := yaml.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("reading ballot %v: %w", path, err)
+	}
+	return b, nil
+}
+
+// Save writes the ballot to path, replacing it in one go so a vote being cast
+// at the same time never sees half a file
+func (b *Ballot) Save(path string) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// candidate finds a film on the ballot by its IMDB or TMDB ID, or its number,
+// counting from 1. TMDB IDs are numbers too, so they are tried first
+func (b *Ballot) candidate(ref string) (*Movie, error) {
+	if i := shortlistIndex(b.Candidates, ref); i >= 0 {
+		return b.Candidates[i], nil
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(b.Candidates) {
+			return nil, fmt.Errorf("film is not on the ballot, and candidate numbers are between 1 and %v: %v", len(b.Candidates), ref)
+		}
+		return b.Candidates[n-1], nil
+	}
+	return nil, fmt.Errorf("film is not on the ballot: %v", ref)
+}
+
+// Cast records the voter's ranking, replacing any earlier vote of theirs
+func (b *Ballot) Cast(voter string, ranking []string, now time.Time) error {
+	voter = strings.TrimSpace(voter)
+	if voter == "" {
+		return errors.New("a vote needs the voter's name")
+	}
+	if len(ranking) == 0 {
+		return errors.New("a vote needs at least one film")
+	}
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, ref := range ranking {
+		m, err := b.candidate(ref)
+		if err != nil {
+			return err
+		}
+		id := shortlistID(m)
+		if seen[id] {
+			return fmt.Errorf("%v is ranked more than once", m.Title)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	vote := &Vote{Voter: voter, Ranking: ids, Cast: now}
+	for i, v := range b.Votes {
+		if strings.EqualFold(v.Voter, voter) {
+			b.Votes[i] = vote
+			return nil
+		}
+	}
+	b.Votes = append(b.Votes, vote)
+	return nil
+}
+
+// Voters are the names of everyone who has voted
+func (b *Ballot) Voters() []string {
+	ret := []string{}
+	for _, v := range b.Votes {
+		ret = append(ret, v.Voter)
+	}
+	return ret
+}
+
+// TallyCount is how many votes a film has
+type TallyCount struct {
+	Title string `yaml:"title" json:"title"`
+	Votes int    `yaml:"votes" json:"votes"`
+}
+
+// TallyRound is one round of instant runoff
+type TallyRound struct {
+	Round  int           `yaml:"round" json:"round"`
+	Counts []*TallyCount `yaml:"counts" json:"counts"`
+	// Exhausted votes have no films left in the running
+	Exhausted  int      `yaml:"exhausted,omitempty" json:"exhausted,omitempty"`
+	Eliminated []string `yaml:"eliminated,omitempty" json:"eliminated,omitempty"`
+}
+
+// TallyPair is how two films did against each other in a Schulze count
+type TallyPair struct {
+	A       string `yaml:"a" json:"a"`
+	B       string `yaml:"b" json:"b"`
+	PreferA int    `yaml:"prefer_a" json:"prefer_a"`
+	PreferB int    `yaml:"prefer_b" json:"prefer_b"`
+}
+
+// TallyResult is the outcome of a vote
+type TallyResult struct {
+	Method string `yaml:"method" json:"method"`
+	Votes  int    `yaml:"votes" json:"votes"`
+	Winner string `yaml:"winner" json:"winner"`
+	// Rounds are the instant runoff rounds
+	Rounds []*TallyRound `yaml:"rounds,omitempty" json:"rounds,omitempty"`
+	// Ranking and Pairs are the Schulze order and head to head counts
+	Ranking []string     `yaml:"ranking,omitempty" json:"ranking,omitempty"`
+	Pairs   []*TallyPair `yaml:"pairs,omitempty" json:"pairs,omitempty"`
+}
+
+func candidateName(m *Movie) string {
+	if m.ReleaseYear > 0 {
+		return fmt.Sprintf("%v (%v)", m.Title, m.ReleaseYear)
+	}
+	return m.Title
+}
+
+// rankings are the votes as indexes in to Candidates. IDs no longer on the
+// ballot are skipped
+func (b *Ballot) rankings() [][]int {
+	ret := [][]int{}
+	for _, v := range b.Votes {
+		r := []int{}
+		for _, id := range v.Ranking {
+			if i := shortlistIndex(b.Candidates, id); i >= 0 {
+				r = append(r, i)
+			}
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
+
+// Tally counts the votes with the given method
+func (b *Ballot) Tally(method string) (*TallyResult, error) {
+	if len(b.Votes) == 0 {
+		return nil, errNoVotes
+	}
+	switch method {
+	case TallyInstantRunoff:
+		return b.tallyInstantRunoff(), nil
+	case TallySchulze:
+		return b.tallySchulze(), nil
+	default:
+		return nil, fmt.Errorf("unknown tally method: %v", method)
+	}
+}
+
+// tallyInstantRunoff runs rounds until a film has a majority of the votes
+// still in play. Films with no votes at all go out together, otherwise the
+// one with the fewest goes, ties knocking out whichever is further down the
+// ballot
+func (b *Ballot) tallyInstantRunoff() *TallyResult {
+	rankings := b.rankings()
+	res := &TallyResult{Method: TallyInstantRunoff, Votes: len(rankings)}
+	running := map[int]bool{}
+	for i := range b.Candidates {
+		running[i] = true
+	}
+	for round := 1; ; round++ {
+		counts := map[int]int{}
+		exhausted := 0
+		for _, r := range rankings {
+			found := false
+			for _, i := range r {
+				if running[i] {
+					counts[i]++
+					found = true
+					break
+				}
+			}
+			if !found {
+				exhausted++
+			}
+		}
+		order := []int{}
+		for i := range b.Candidates {
+			if running[i] {
+				order = append(order, i)
+			}
+		}
+		sort.SliceStable(order, func(x, y int) bool {
+			return counts[order[x]] > counts[order[y]]
+		})
+		tr := &TallyRound{Round: round, Exhausted: exhausted}
+		for _, i := range order {
+			tr.Counts = append(tr.Counts, &TallyCount{Title: candidateName(b.Candidates[i]), Votes: counts[i]})
+		}
+		res.Rounds = append(res.Rounds, tr)
+
+		leader := order[0]
+		if len(order) == 1 || counts[leader]*2 > len(rankings)-exhausted {
+			res.Winner = candidateName(b.Candidates[leader])
+			return res
+		}
+		out := []int{}
+		for _, i := range order {
+			if counts[i] == 0 {
+				out = append(out, i)
+			}
+		}
+		if len(out) == 0 || len(out) == len(order) {
+			// order is stable, so the last of the lowest is furthest down the
+			// ballot
+			out = []int{order[len(order)-1]}
+		}
+		for _, i := range out {
+			running[i] = false
+			tr.Eliminated = append(tr.Eliminated, candidateName(b.Candidates[i]))
+		}
+	}
+}
+
+// tallySchulze ranks the films by the strongest chains of head to head wins
+// between them. Any tie is broken by ballot order
+func (b *Ballot) tallySchulze() *TallyResult {
+	rankings := b.rankings()
+	n := len(b.Candidates)
+	// d[i][j] is how many voters prefer i to j
+	d := make([][]int, n)
+	p := make([][]int, n)
+	for i := range d {
+		d[i] = make([]int, n)
+		p[i] = make([]int, n)
+	}
+	for _, r := range rankings {
+		ranked := map[int]bool{}
+		for _, i := range r {
+			for j := 0; j < n; j++ {
+				if j != i && !ranked[j] {
+					d[i][j]++
+				}
+			}
+			ranked[i] = true
+		}
+	}
+	// p[i][j] is the strength of the strongest path from i to j
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j && d[i][j] > d[j][i] {
+				p[i][j] = d[i][j]
+			}
+		}
+	}
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			if i == k {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if j == i || j == k {
+					continue
+				}
+				if s := minInt(p[i][k], p[k][j]); s > p[i][j] {
+					p[i][j] = s
+				}
+			}
+		}
+	}
+	beats := make([]int, n)
+	order := make([]int, n)
+	for i := 0; i < n; i++ {
+		order[i] = i
+		for j := 0; j < n; j++ {
+			if p[i][j] > p[j][i] {
+				beats[i]++
+			}
+		}
+	}
+	sort.SliceStable(order, func(x, y int) bool {
+		return beats[order[x]] > beats[order[y]]
+	})
+
+	res := &TallyResult{Method: TallySchulze, Votes: len(rankings)}
+	for _, i := range order {
+		res.Ranking = append(res.Ranking, candidateName(b.Candidates[i]))
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			res.Pairs = append(res.Pairs, &TallyPair{
+				A:       candidateName(b.Candidates[i]),
+				B:       candidateName(b.Candidates[j]),
+				PreferA: d[i][j],
+				PreferB: d[j][i],
+			})
+		}
+	}
+	res.Winner = res.Ranking[0]
+	return res
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Ballot returns the open vote
+func (c *Client) Ballot() (*Ballot, error) {
+	if c.Config == nil || c.Config.BallotPath == "" {
+		return nil, errNoBallotPath
+	}
+	return LoadBallot(c.Config.BallotPath)
+}
+
+// OpenVote starts a new vote between the movies, replacing any open one
+func (c *Client) OpenVote(movies []*Movie) (*Ballot, error) {
+	if c.Config == nil || c.Config.BallotPath == "" {
+		return nil, errNoBallotPath
+	}
+	b, err := NewBallot(movies, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return b, b.Save(c.Config.BallotPath)
+}
+
+// CastVote records a vote on the open ballot. Candidates are given by their
+// number on the ballot, or IMDB or TMDB ID
+func (c *Client) CastVote(voter string, ranking []string) (*Ballot, error) {
+	b, err := c.Ballot()
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Cast(voter, ranking, time.Now()); err != nil {
+		return nil, err
+	}
+	return b, b.Save(c.Config.BallotPath)
+}
@@ -0,0 +1,131 @@
+package letswatch
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// castAll casts count votes with the same ranking, given by letter
+func castAll(t *testing.T, b *Ballot, count int, ranking string) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		refs := []string{}
+		for _, c := range ranking {
+			refs = append(refs, b.Candidates[c-'A'].IMDBID)
+		}
+		require.NoError(t, b.Cast(ranking+string(rune('a'+len(b.Votes))), refs, time.Time{}))
+	}
+}
+
+func TestBallotCast(t *testing.T) {
+	_, err := NewBallot(bracketTestMovies(1), time.Now())
+	require.EqualError(t, err, "a vote needs at least 2 films")
+
+	b, err := NewBallot(bracketTestMovies(3), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, b.Cast("Alice", []string{"2", "tt0000001"}, time.Now()))
+	require.Equal(t, []string{"tt0000002", "tt0000001"}, b.Votes[0].Ranking)
+	// Voting again replaces it
+	require.NoError(t, b.Cast("alice", []string{"3"}, time.Now()))
+	require.Equal(t, []string{"alice"}, b.Voters())
+
+	require.EqualError(t, b.Cast("Bob", []string{"4"}, time.Now()), "film is not on the ballot, and candidate numbers are between 1 and 3: 4")
+	// A TMDB ID is a number, but it isn't taken as a position
+	b.Candidates[2].TMDBID = "550"
+	require.NoError(t, b.Cast("Bob", []string{"550"}, time.Now()))
+	require.Equal(t, []string{"tt0000003"}, b.Votes[1].Ranking)
+	require.EqualError(t, b.Cast("Bob", []string{"tt0000009"}, time.Now()), "film is not on the ballot: tt0000009")
+	require.EqualError(t, b.Cast("Bob", []string{"1", "tt0000001"}, time.Now()), "A is ranked more than once")
+	require.EqualError(t, b.Cast(" ", []string{"1"}, time.Now()), "a vote needs the voter's name")
+
+	path := filepath.Join(t.TempDir(), "ballot.yaml")
+	_, err = LoadBallot(path)
+	require.Equal(t, errNoVote, err)
+	require.NoError(t, b.Save(path))
+	got, err := LoadBallot(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "B", "C"}, movieTitles(got.Candidates))
+	require.Equal(t, []string{"tt0000003"}, got.Votes[0].Ranking)
+}
+
+func TestTallyInstantRunoff(t *testing.T) {
+	b, err := NewBallot(bracketTestMovies(4), time.Now())
+	require.NoError(t, err)
+	_, err = b.Tally(TallyInstantRunoff)
+	require.Equal(t, errNoVotes, err)
+
+	castAll(t, b, 4, "A")
+	castAll(t, b, 3, "BA")
+	castAll(t, b, 2, "CB")
+	castAll(t, b, 1, "D")
+	res, err := b.Tally(TallyInstantRunoff)
+	require.NoError(t, err)
+	// A leads on first choices, but C's votes put B over
+	require.Equal(t, "B", res.Winner)
+	require.Len(t, res.Rounds, 3)
+	require.Equal(t, []string{"D"}, res.Rounds[0].Eliminated)
+	require.Equal(t, 1, res.Rounds[1].Exhausted)
+	require.Equal(t, []string{"C"}, res.Rounds[1].Eliminated)
+	require.Equal(t, []*TallyCount{{Title: "B", Votes: 5}, {Title: "A", Votes: 4}}, res.Rounds[2].Counts)
+
+	_, err = b.Tally("borda")
+	require.EqualError(t, err, "unknown tally method: borda")
+}
+
+func TestTallySchulze(t *testing.T) {
+	// The example from Schulze's paper, which E wins
+	b, err := NewBallot(bracketTestMovies(5), time.Now())
+	require.NoError(t, err)
+	castAll(t, b, 5, "ACBED")
+	castAll(t, b, 5, "ADECB")
+	castAll(t, b, 8, "BEDAC")
+	castAll(t, b, 3, "CABED")
+	castAll(t, b, 7, "CAEBD")
+	castAll(t, b, 2, "CBADE")
+	castAll(t, b, 7, "DCEBA")
+	castAll(t, b, 8, "EBADC")
+	res, err := b.Tally(TallySchulze)
+	require.NoError(t, err)
+	require.Equal(t, "E", res.Winner)
+	require.Equal(t, []string{"E", "A", "C", "B", "D"}, res.Ranking)
+	require.Equal(t, &TallyPair{A: "A", B: "B", PreferA: 20, PreferB: 25}, res.Pairs[0])
+}
+
+func TestServerVote(t *testing.T) {
+	s := newTestServer(t)
+	s.client.Config.BallotPath = filepath.Join(t.TempDir(), "ballot.yaml")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	require.Equal(t, http.StatusNotFound, do("GET", "/v1/vote", "").Code)
+
+	_, err := s.client.OpenVote(bracketTestMovies(3))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/v1/vote", `{"voter":"alice","ranking":["7"]}`).Code)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/v1/vote", `not json`).Code)
+	require.Equal(t, http.StatusOK, do("POST", "/v1/vote", `{"voter":"alice","ranking":["2","1"]}`).Code)
+	rec := do("POST", "/v1/vote", `{"voter":"bob","ranking":["tt0000002"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got BallotResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	require.Equal(t, []string{"alice", "bob"}, got.Voters)
+	require.Len(t, got.Candidates, 3)
+
+	rec = do("GET", "/v1/vote/tally?method=schulze", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res TallyResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, "B", res.Winner)
+	require.Equal(t, http.StatusBadRequest, do("GET", "/v1/vote/tally?method=borda", "").Code)
+	// Only votes can be sent
+	require.Equal(t, http.StatusMethodNotAllowed, do("POST", "/v1/vote/tally", "").Code)
+}
@@ -0,0 +1,81 @@
+package letswatch
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Sources of a watched signal
+const (
+	WatchedSourceLetterboxd = "letterboxd"
+	WatchedSourcePlex       = "plex"
+)
+
+// WatchedSet maps an IMDB ID to the sources that say it has been watched
+type WatchedSet map[string][]string
+
+// Add records that the given source says the film was watched
+func (w WatchedSet) Add(imdbID, source string) {
+	if imdbID == "" || ContainsString(w[imdbID], source) {
+		return
+	}
+	w[imdbID] = append(w[imdbID], source)
+}
+
+// Contains returns true if any source says the film was watched
+func (w WatchedSet) Contains(imdbID string) bool {
+	_, ok := w[imdbID]
+	return ok
+}
+
+// Sources returns where the watched signal for a film came from
+func (w WatchedSet) Sources(imdbID string) []string {
+	return w[imdbID]
+}
+
+// Counts returns the number of watched films seen from each source
+func (w WatchedSet) Counts() map[string]int {
+	ret := map[string]int{}
+	for _, sources := range w {
+		for _, s := range sources {
+			ret[s]++
+		}
+	}
+	return ret
+}
+
+// Watched collects the films the user has watched from Letterboxd, and from
+// the Plex play history if that is enabled in the config
+func (c *Client) Watched(ctx context.Context, username string) (WatchedSet, error) {
+	ret := WatchedSet{}
+	var ids []string
+	err := c.Resilience.Do(ctx, ServiceLetterboxd, func(ctx context.Context) error {
+		var err error
+		ids, err = c.LetterboxdClient.Film.GetWatchedIMDBIDs(ctx, username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		ret.Add(id, WatchedSourceLetterboxd)
+	}
+
+	if c.Config != nil && c.Config.PlexWatched {
+		if c.Plex == nil {
+			return nil, errors.New("plex watched history requested, but plex is not configured")
+		}
+		// Letterboxd is enough to go on if Plex is down
+		ids, err := c.Plex.WatchedIMDBIDs(ctx, c.Config.PlexWatchedUsers)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error getting the Plex watch history, only using Letterboxd")
+		}
+		for _, id := range ids {
+			ret.Add(id, WatchedSourcePlex)
+		}
+	}
+	log.Debug().Interface("counts", ret.Counts()).Msg("Collected watched films")
+	return ret, nil
+}
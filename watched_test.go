@@ -0,0 +1,66 @@
+package letswatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/drewstinnett/go-letterboxd"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedSet(t *testing.T) {
+	w := WatchedSet{}
+	w.Add("tt4016934", WatchedSourceLetterboxd)
+	w.Add("tt4016934", WatchedSourcePlex)
+	w.Add("tt4016934", WatchedSourcePlex)
+	w.Add("tt0111161", WatchedSourcePlex)
+	w.Add("", WatchedSourcePlex)
+
+	require.True(t, w.Contains("tt4016934"))
+	require.False(t, w.Contains("tt0000001"))
+	require.Equal(t, []string{WatchedSourceLetterboxd, WatchedSourcePlex}, w.Sources("tt4016934"))
+	require.Equal(t, map[string]int{
+		WatchedSourceLetterboxd: 1,
+		WatchedSourcePlex:       2,
+	}, w.Counts())
+}
+
+func TestPlexWatchedIMDBIDs(t *testing.T) {
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	history, err := ioutil.ReadFile("testdata/plex_history.json")
+	require.NoError(t, err)
+	library, err := ioutil.ReadFile("testdata/plex_section_all.json")
+	require.NoError(t, err)
+	httpmock.RegisterResponder("GET", "https://plex.example.com/accounts",
+		httpmock.NewStringResponder(200, `{"MediaContainer":{"Account":[{"id":1,"name":"drew"},{"id":2,"name":"kid"}]}}`))
+	httpmock.RegisterResponder("GET", "https://plex.example.com/status/sessions/history/all?type=1&accountID=2",
+		httpmock.NewStringResponder(200, string(history)))
+	httpmock.RegisterResponder("GET", "https://plex.example.com/library/sections",
+		httpmock.NewStringResponder(200, `{"MediaContainer":{"Directory":[{"key":"1","type":"movie","title":"Movies"},{"key":"2","type":"show","title":"TV"}]}}`))
+	httpmock.RegisterResponder("GET", "https://plex.example.com/library/sections/1/all?includeGuids=1",
+		httpmock.NewStringResponder(200, string(library)))
+
+	c, err := NewClient(ClientConfig{
+		TMDBKey:   "foo",
+		PlexURL:   "https://plex.example.com",
+		PlexToken: "foo",
+		LetterboxdConfig: &letterboxd.ClientConfig{
+			DisableCache: true,
+		},
+	})
+	require.NoError(t, err)
+
+	// The deleted film and the unwatched one are left out, and the library is
+	// fetched once rather than an item at a time
+	got, err := c.Plex.WatchedIMDBIDs(context.Background(), []string{"kid"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"tt4016934"}, got)
+	require.Equal(t, 4, httpmock.GetTotalCallCount())
+
+	_, err = c.Plex.WatchedIMDBIDs(context.Background(), []string{"nobody"})
+	require.Error(t, err)
+}